@@ -0,0 +1,77 @@
+/*
+ * File: bundle.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/zitelog/mrsign/dirhash"
+)
+
+const BundleExt = ".mrsig"
+
+const BundleVersion = 1
+
+// Bundle is a detached signature: the client store, with the server receipt,
+// and the manifest, if any, saved in a file out of the signed folder
+type Bundle struct {
+	Version  int               `json:"version"`
+	Store    ClientStore       `json:"store"`
+	Manifest *dirhash.Manifest `json:"manifest,omitempty"`
+}
+
+func ReadBundle(file string) (*Bundle, error) {
+	body, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{}
+	if err = json.Unmarshal(body, b); err != nil {
+		return nil, err
+	}
+	if b.Version != BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", b.Version)
+	}
+	return b, nil
+}
+
+func (b *Bundle) Save(file string) error {
+	pr, _ := json.MarshalIndent(b, "", "\t")
+	return ioutil.WriteFile(file, pr, 0644)
+}
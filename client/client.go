@@ -0,0 +1,777 @@
+/*
+ * File: client.go
+ * Project: mrsign
+ * Created Date: Tuesday, October 19th 2021, 12:16:54 pm
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package client
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/zitelog/mrsign/dirhash"
+	"github.com/zitelog/mrsign/protocol"
+)
+
+/*
+func getIps() ([]string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, a := range addrs {
+		if ipNet, ok := a.(*net.IPNet); ok && !ipNet.IP.IsLoopback() {
+			if ipNet.IP.To4() != nil {
+				out = append(out, ipNet.IP.String())
+			}
+		}
+	}
+	return out, nil
+}
+*/
+
+// Trust of the timestamp token of a receipt
+const (
+	// TimestampTrusted is a token of a TSA chaining to the trusted certificates
+	TimestampTrusted = "trusted"
+	// TimestampUntrusted is a token checked without trusted certificates,
+	// which anyone could have issued
+	TimestampUntrusted = "untrusted"
+)
+
+type Client struct {
+	urlChallenge    string
+	urlRetrieve     string
+	urlPublicKey    string
+	urlLedgerHead   string
+	urlSignatures   string
+	path            string
+	storeFile       string
+	manifestFile    string
+	serverStoreFile string
+	target          string
+	archive         bool
+	bundle          bool
+	bundleManifest  *dirhash.Manifest
+	folderHash      string
+	manifest        bool
+	hash            string
+	metadata        bool
+	symlinks        string
+	special         string
+	concurrency     int
+	include         []string
+	exclude         []string
+	progress        func(dirhash.Progress)
+	tsaRoots        *x509.CertPool
+	supersede       string
+	revision        int
+	latest          *protocol.Receipt
+	timestamp       string
+	logger          *slog.Logger
+}
+
+func NewClient(server string, path string, clientStoreFile string, serverStoreFilePath string) *Client {
+	if len(clientStoreFile) == 0 {
+		clientStoreFile = ClientStoreFile
+	}
+
+	storeFile := path + string(os.PathSeparator) + clientStoreFile
+	target := ""
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		// the store of a file is written next to it
+		storeFile = path + "." + clientStoreFile
+		target = TargetFile
+	}
+
+	return &Client{
+		urlChallenge:    server + protocol.ApiChallenge,
+		urlRetrieve:     server + protocol.ApiRetrieve,
+		urlPublicKey:    server + protocol.ApiPublicKey,
+		urlLedgerHead:   server + protocol.ApiLedgerHead,
+		urlSignatures:   server + protocol.ApiSignatures,
+		path:            path,
+		storeFile:       storeFile,
+		manifestFile:    strings.TrimSuffix(storeFile, filepath.Ext(storeFile)) + ClientManifestExt,
+		serverStoreFile: serverStoreFilePath + string(os.PathSeparator) + clientStoreFile,
+		target:          target,
+		logger:          slog.Default(),
+	}
+}
+
+func (c *Client) SetManifest(enable bool) {
+	c.manifest = enable
+}
+
+// SetBundle saves the signature in the detached bundle file instead of the
+// client store and manifest files
+func (c *Client) SetBundle(file string) {
+	c.bundle = true
+	c.storeFile = file
+	c.manifestFile = ""
+}
+
+// SetArchive makes new signatures of a file cover the entries of the zip or
+// tar archive instead of its bytes
+func (c *Client) SetArchive(enable bool) {
+	c.archive = enable
+}
+
+// SetHash sets the folder hash scheme of new signatures (dirhash.SchemeH1,
+// SchemeM1 or SchemeH2), verification uses the scheme saved in the client store
+func (c *Client) SetHash(scheme string) {
+	c.hash = scheme
+}
+
+// SetMetadata makes the hash of new signatures cover the file modes,
+// modification times, symbolic link targets and empty directories
+func (c *Client) SetMetadata(enable bool) {
+	c.metadata = enable
+}
+
+// SetPolicies sets the symbolic link (dirhash.SymlinkFollow, SymlinkLink or
+// SymlinkSkip) and special file (dirhash.SpecialFail or SpecialSkip) policies
+// of new signatures, the dirhash defaults when empty
+func (c *Client) SetPolicies(symlinks string, special string) {
+	c.symlinks = symlinks
+	c.special = special
+}
+
+// SetPatterns sets the include and exclude patterns of new signatures, added
+// to the ones of the folder dirhash.IgnoreFile
+func (c *Client) SetPatterns(include []string, exclude []string) {
+	c.include = include
+	c.exclude = exclude
+}
+
+// SetConcurrency sets the number of files hashed at once, all the CPUs when 0
+func (c *Client) SetConcurrency(n int) {
+	c.concurrency = n
+}
+
+// SetProgress sets the callback of the folder hashing progress
+func (c *Client) SetProgress(progress func(dirhash.Progress)) {
+	c.progress = progress
+}
+
+// SetTSARoots sets the trusted TSA certificates, offline verification then
+// requires a timestamp token signed by one of them
+func (c *Client) SetTSARoots(roots *x509.CertPool) {
+	c.tsaRoots = roots
+}
+
+// SetSupersede makes Generate sign a new revision of the signature of the
+// client path, superseding the current one for reason
+func (c *Client) SetSupersede(reason string) {
+	c.supersede = reason
+}
+
+// SetRevision makes Restore and VerifyOffline verify the revision n of the
+// signature kept in the client store, the one of the store when 0
+func (c *Client) SetRevision(n int) {
+	c.revision = n
+}
+
+func (c *Client) SetLogger(logger *slog.Logger) {
+	c.logger = logger
+}
+
+func (c *Client) Generate(user string, _ string, hostname string) error {
+	reqNegotiate := protocol.NewMessageNegotiate()
+	reqNegotiate.UserName = user
+	reqNegotiate.HostName = hostname
+	reqNegotiate.FolderName = c.path
+
+	out, err := c.newStore()
+	if err != nil {
+		return err
+	}
+	out.User = user
+	out.HostName = hostname
+	out.ClientChallenge = reqNegotiate.ClientChallenge
+	if out.Metadata {
+		reqNegotiate.Flags.Set(protocol.NegotiateFlagNEGOTIATEMETADATA)
+	}
+	challengeUrl := c.urlChallenge
+	if len(c.supersede) > 0 {
+		challengeUrl += "?" + url.Values{protocol.ParamSupersede: {c.supersede}}.Encode()
+		if c.Exists() {
+			previous, err := c.loadStore()
+			if err != nil {
+				return err
+			}
+			for _, r := range previous.History() {
+				// the manifest file is replaced by the one of the new revision
+				r.Manifest = ""
+				out.Previous = append(out.Previous, r)
+			}
+			// so is the bundle manifest, dropped when no new one is made
+			c.bundleManifest = nil
+		}
+	}
+
+	restore := c.snapshot()
+	if err = c.saveStore(out); err != nil {
+		return err
+	}
+
+	var folderHash string
+	if c.manifest {
+		folderHash, err = c.createManifest(out)
+	} else {
+		folderHash, err = c.createFolderHash(out)
+	}
+	if err != nil {
+		restore()
+		return err
+	}
+	c.logger.Debug("folder hash", "path", c.path, "manifest", c.manifest, "hash", folderHash)
+
+	resBody, err := c.handshake(challengeUrl, reqNegotiate, folderHash)
+	if err != nil {
+		restore()
+		c.logger.Warn("generate", "user", user, "host", hostname, "path", c.path, "outcome", "failed", "error", err.Error())
+		return err
+	}
+	if err = json.Unmarshal(resBody, &out.Receipt); err != nil {
+		restore()
+		return err
+	}
+	args := []any{"key", out.Receipt.Key, "user", user, "host", hostname, "path", c.path, "sid", out.Receipt.SID, "outcome", "signed"}
+	if len(out.Receipt.Supersedes) > 0 {
+		args = append(args, "revision", out.Receipt.Revision, "supersedes", out.Receipt.Supersedes)
+	}
+	c.logger.Info("generate", args...)
+	return c.saveStore(out)
+}
+
+// snapshot returns a function putting back the store and manifest files as
+// they are now, removing the missing ones
+func (c *Client) snapshot() func() {
+	files := []string{c.storeFile, c.manifestFile}
+	data := make([][]byte, len(files))
+	for i, f := range files {
+		data[i], _ = os.ReadFile(f)
+	}
+	return func() {
+		for i, f := range files {
+			if data[i] == nil {
+				_ = os.Remove(f)
+			} else {
+				_ = os.WriteFile(f, data[i], 0644)
+			}
+		}
+	}
+}
+
+// Hash returns the hash a new signature of the client path would sign
+func (c *Client) Hash() (string, error) {
+	store, err := c.newStore()
+	if err != nil {
+		return "", err
+	}
+	return c.createFolderHash(store)
+}
+
+// newStore returns the client store of a new signature, with the hash
+// settings of the client
+func (c *Client) newStore() (*ClientStore, error) {
+	if _, err := dirhash.SchemeHashFunc(c.hash); err != nil {
+		return nil, err
+	}
+	out := NewClientStore()
+	out.Path = c.path
+	out.Target = c.target
+	if c.archive {
+		if c.target != TargetFile {
+			return nil, errors.New("not an archive: " + c.path)
+		}
+		out.Target = TargetArchive
+	}
+	out.Hash = c.hash
+	out.Metadata = c.metadata
+	out.Symlinks = c.symlinks
+	if len(out.Symlinks) == 0 {
+		out.Symlinks = dirhash.DefaultSymlinks(out.Metadata)
+	}
+	out.Special = c.special
+	var err error
+	if len(out.Target) == 0 {
+		if out.Patterns, err = c.patterns(); err != nil {
+			return nil, err
+		}
+	} else if len(c.include) > 0 || len(c.exclude) > 0 {
+		return nil, errors.New("include and exclude patterns apply to folders only")
+	}
+	if c.manifest && !c.bundle {
+		out.Manifest = filepath.Base(c.manifestFile)
+	}
+	return out, nil
+}
+
+// FolderHash returns the hash computed by the last Generate, Restore,
+// VerifyOffline or Hash
+func (c *Client) FolderHash() string {
+	return c.folderHash
+}
+
+// Path returns the client path
+func (c *Client) Path() string {
+	return c.path
+}
+
+// Store returns the saved client store of the signature
+func (c *Client) Store() (*ClientStore, error) {
+	return c.loadStore()
+}
+
+func (c *Client) Exists() bool {
+	exists := false
+	if a, e := os.Stat(c.storeFile); e == nil {
+		if !a.IsDir() {
+			exists = true
+		}
+	}
+	return exists
+}
+
+func (c *Client) Restore() error {
+	store, err := c.revisionStore()
+	if err != nil {
+		return err
+	}
+	folderHash, err := c.createFolderHash(store)
+	if err != nil {
+		return err
+	}
+	reqNegotiate := protocol.NewMessageNegotiate()
+	reqNegotiate.UserName = store.User
+	reqNegotiate.HostName = store.HostName
+	reqNegotiate.FolderName = store.Path
+	reqNegotiate.ClientChallenge = store.ClientChallenge
+	if store.Metadata {
+		reqNegotiate.Flags.Set(protocol.NegotiateFlagNEGOTIATEMETADATA)
+	}
+
+	revision := store.Receipt.RevisionNumber()
+	retrieveUrl := c.urlRetrieve + "?" + url.Values{protocol.ParamRevision: {strconv.Itoa(revision)}}.Encode()
+	resBody, err := c.handshake(retrieveUrl, reqNegotiate, folderHash)
+	if err != nil {
+		c.logger.Warn("restore", "key", store.Receipt.Key, "user", store.User, "host", store.HostName, "path", store.Path, "sid", store.Receipt.SID, "revision", revision, "outcome", "failed", "error", err.Error())
+		return err
+	}
+	c.latest = nil
+	if len(resBody) > 0 {
+		// servers without revisions answer an empty body
+		latest := protocol.Receipt{}
+		if err = json.Unmarshal(resBody, &latest); err != nil {
+			return err
+		}
+		c.latest = &latest
+	}
+	c.logger.Info("restore", "key", store.Receipt.Key, "user", store.User, "host", store.HostName, "path", store.Path, "sid", store.Receipt.SID, "revision", revision, "outcome", "verified")
+	return nil
+}
+
+// Latest returns the receipt of the latest revision of the signature, as
+// answered by the server to the last Restore, nil for the servers without
+// revisions
+func (c *Client) Latest() *protocol.Receipt {
+	return c.latest
+}
+
+// revisionStore returns the client store of the revision to verify
+func (c *Client) revisionStore() (*ClientStore, error) {
+	store, err := c.loadStore()
+	if err != nil || c.revision == 0 {
+		return store, err
+	}
+	r, ok := store.AtRevision(c.revision)
+	if !ok {
+		return nil, fmt.Errorf("revision %d not in the client store", c.revision)
+	}
+	return r, nil
+}
+
+// VerifyOffline checks the receipt signature with the server public key and
+// the folder against the receipt, without contacting the server
+func (c *Client) VerifyOffline(key ed25519.PublicKey) error {
+	c.timestamp = ""
+	store, err := c.revisionStore()
+	if err != nil {
+		return err
+	}
+	if err = store.Receipt.VerifySignature(key); err != nil {
+		return err
+	}
+	if len(store.Receipt.ServerChallenge) == 0 {
+		return errors.New("receipt does not support offline verification")
+	}
+	folderHash, err := c.createFolderHash(store)
+	if err != nil {
+		return err
+	}
+	if err = store.Receipt.VerifyResult(folderHash, store.ClientChallenge); err != nil {
+		if errors.Is(err, protocol.ErrDifferentSignature) {
+			err = fmt.Errorf("%w: %w", ErrMismatch, err)
+		}
+		c.logger.Warn("verify", "key", store.Receipt.Key, "user", store.User, "host", store.HostName, "path", store.Path, "sid", store.Receipt.SID, "outcome", "failed", "error", err.Error())
+		return err
+	}
+	args := []any{"key", store.Receipt.Key, "user", store.User, "host", store.HostName, "path", store.Path, "sid", store.Receipt.SID}
+	if len(store.Receipt.TimestampToken) > 0 || c.tsaRoots != nil {
+		signedAt, err := store.Receipt.VerifyTimestamp(c.tsaRoots)
+		if err != nil && !errors.Is(err, protocol.ErrUntrustedTimestamp) {
+			c.logger.Warn("verify", append(args, "outcome", "failed", "error", err.Error())...)
+			return err
+		}
+		c.timestamp = TimestampTrusted
+		if err != nil {
+			c.timestamp = TimestampUntrusted
+		}
+		args = append(args, "timestamped", signedAt, "timestamp", c.timestamp)
+	}
+	c.logger.Info("verify", append(args, "outcome", "verified")...)
+	return nil
+}
+
+// Timestamp returns the trust of the timestamp token checked by the last
+// VerifyOffline, TimestampTrusted or TimestampUntrusted, empty without token
+func (c *Client) Timestamp() string {
+	return c.timestamp
+}
+
+func (c *Client) PublicKey() (ed25519.PublicKey, []byte, error) {
+	body, err := c.get(c.urlPublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := protocol.ParsePublicKey(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, body, nil
+}
+
+// LedgerHead returns the current head of the server ledger, signed by the
+// server
+func (c *Client) LedgerHead() (*protocol.LedgerHead, error) {
+	body, err := c.get(c.urlLedgerHead)
+	if err != nil {
+		return nil, err
+	}
+	var head protocol.LedgerHead
+	if err = json.Unmarshal(body, &head); err != nil {
+		return nil, err
+	}
+	return &head, nil
+}
+
+func (c *Client) Diff() (*dirhash.ManifestDiff, error) {
+	store, err := c.loadStore()
+	if err != nil {
+		return nil, err
+	}
+	signed := c.bundleManifest
+	if signed == nil {
+		if len(store.Manifest) == 0 {
+			return nil, errors.New("signature has no manifest")
+		}
+		body, err := ioutil.ReadFile(filepath.Join(filepath.Dir(c.storeFile), store.Manifest))
+		if err != nil {
+			return nil, err
+		}
+		signed = &dirhash.Manifest{}
+		if err = json.Unmarshal(body, signed); err != nil {
+			return nil, err
+		}
+	}
+	current, err := c.folderManifest(store)
+	if err != nil {
+		return nil, err
+	}
+	return signed.Diff(current), nil
+}
+
+func (c *Client) handshake(url string, reqNegotiate *protocol.NegotiateMessage, folderHash string) ([]byte, error) {
+	reqNegotiate.Flags.Set(protocol.NegotiateFlagsMAC)
+	reqNegotiateBody, err := reqNegotiate.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	resChallengeBody, err := c.post(url, reqNegotiateBody)
+	if err != nil {
+		return nil, err
+	}
+	resChallenge := protocol.NewMessageChallenge()
+	if err = resChallenge.Unmarshal(resChallengeBody); err != nil {
+		return nil, err
+	}
+	reqAuthenticate := protocol.NewMessageAuthenticate()
+	if err = reqAuthenticate.Build(resChallenge, reqNegotiate, []byte(folderHash)); err != nil {
+		return nil, err
+	}
+	reqAuthenticateBody, err := reqAuthenticate.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return c.post(url, reqAuthenticateBody)
+}
+
+// Signatures lists the signatures of the server matching q
+func (c *Client) Signatures(q protocol.SignatureQuery) (*protocol.SignatureList, error) {
+	body, err := c.get(c.urlSignatures + "?" + q.Values().Encode())
+	if err != nil {
+		return nil, err
+	}
+	list := &protocol.SignatureList{}
+	if err = json.Unmarshal(body, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Signature returns the signature of the server with the given SID, or the
+// superseded revision with it
+func (c *Client) Signature(sid string) (*protocol.SignatureInfo, error) {
+	body, err := c.get(c.urlSignatures + "/" + url.PathEscape(sid))
+	if err != nil {
+		return nil, err
+	}
+	info := &protocol.SignatureInfo{}
+	if err = json.Unmarshal(body, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Revoke withdraws the signature of the server with the given SID, or the
+// superseded revision with it, for reason, one of the protocol Revoke reasons
+func (c *Client) Revoke(sid string, reason string, comment string) (*protocol.SignatureInfo, error) {
+	data, _ := json.Marshal(protocol.RevokeRequest{Reason: reason, Comment: comment})
+	body, err := c.do(http.MethodPost, c.urlSignatures+"/"+url.PathEscape(sid)+protocol.ApiRevoke, "application/json", data)
+	if err != nil {
+		return nil, err
+	}
+	info := &protocol.SignatureInfo{}
+	if err = json.Unmarshal(body, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (c *Client) get(url string) ([]byte, error) {
+	return c.do(http.MethodGet, url, "", nil)
+}
+
+func (c *Client) post(url string, body []byte) ([]byte, error) {
+	return c.do(http.MethodPost, url, "application/octet-stream", body)
+}
+
+func (c *Client) do(method string, url string, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	resBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, newStatusError(resp, resBody)
+	}
+	return resBody, nil
+}
+
+func (c *Client) saveStore(store *ClientStore) error {
+	if c.bundle {
+		b := &Bundle{Version: BundleVersion, Store: *store, Manifest: c.bundleManifest}
+		return b.Save(c.storeFile)
+	}
+	pr, _ := json.MarshalIndent(store, "", "\t")
+	return ioutil.WriteFile(c.storeFile, pr, 0644)
+}
+
+func (c *Client) loadStore() (*ClientStore, error) {
+	if c.bundle {
+		b, err := ReadBundle(c.storeFile)
+		if err != nil {
+			return nil, err
+		}
+		c.bundleManifest = b.Manifest
+		return &b.Store, nil
+	}
+	body, err := ioutil.ReadFile(c.storeFile)
+	if err != nil {
+		return nil, err
+	}
+	store := NewClientStore()
+	err = json.Unmarshal(body, store)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// folderManifest returns the manifest of the files the signature of store
+// covers: the files of the folder, the file or the entries of the archive
+func (c *Client) folderManifest(store *ClientStore) (*dirhash.Manifest, error) {
+	h := &dirhash.Hasher{
+		Concurrency: c.concurrency,
+		Progress:    c.progress,
+		Patterns:    store.Patterns,
+		Metadata:    store.Metadata,
+		Symlinks:    store.Symlinks,
+		Special:     store.Special,
+	}
+	var m *dirhash.Manifest
+	var err error
+	switch store.Target {
+	case TargetFile:
+		m, err = h.FileManifest(c.path)
+	case TargetArchive:
+		m, err = h.ArchiveManifest(c.path)
+	case "":
+		var exclude []string
+		if store.Patterns == nil {
+			// signatures made before the patterns excluded the tool files by name
+			exclude = c.excludes(store)
+		}
+		m, err = h.Manifest(c.path, "", exclude)
+	default:
+		err = errors.New("unknown signature target " + store.Target)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range m.Skipped {
+		c.logger.Info("file skipped", "path", f.Path, "type", f.Type, "reason", f.Reason)
+	}
+	return m, nil
+}
+
+func (c *Client) createFolderHash(store *ClientStore) (string, error) {
+	m, err := c.folderManifest(store)
+	if err != nil {
+		return "", err
+	}
+	c.folderHash, err = m.SchemeHash(store.Hash)
+	return c.folderHash, err
+}
+
+func (c *Client) createManifest(store *ClientStore) (string, error) {
+	m, err := c.folderManifest(store)
+	if err != nil {
+		return "", err
+	}
+	if c.bundle {
+		// saved with the store
+		c.bundleManifest = m
+	} else {
+		pr, _ := json.MarshalIndent(m, "", "\t")
+		if err = ioutil.WriteFile(c.manifestFile, pr, 0644); err != nil {
+			return "", err
+		}
+	}
+	c.folderHash, err = m.SchemeHash(store.Hash)
+	return c.folderHash, err
+}
+
+// patterns returns the patterns of a new signature: the ones of the folder
+// IgnoreFile, the ones set on the client and the tool files
+func (c *Client) patterns() (*dirhash.Patterns, error) {
+	p := &dirhash.Patterns{Include: c.include}
+	f, err := os.Open(filepath.Join(c.path, dirhash.IgnoreFile))
+	if err == nil {
+		p.Exclude, err = dirhash.ReadPatterns(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	p.Exclude = append(p.Exclude, c.exclude...)
+	for _, file := range []string{c.storeFile, c.manifestFile, c.serverStoreFile} {
+		if len(file) == 0 {
+			continue
+		}
+		rel, err := filepath.Rel(c.path, file)
+		if err != nil || rel == "." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			continue
+		}
+		p.Exclude = append(p.Exclude, "/"+escapePattern(filepath.ToSlash(rel)))
+	}
+	if err = p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// escapePattern quotes the glob characters of a file name
+func escapePattern(name string) string {
+	r := strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`)
+	return r.Replace(name)
+}
+
+// excludes returns the file names excluded by the signatures made before the
+// patterns. The first ones, without receipt, excluded only the server store
+// path, which never matches a name: their client store is hashed.
+func (c *Client) excludes(store *ClientStore) []string {
+	if len(store.Receipt.SID) == 0 {
+		return []string{c.serverStoreFile}
+	}
+	return []string{filepath.Base(c.storeFile), filepath.Base(c.manifestFile), c.serverStoreFile}
+}
@@ -0,0 +1,1131 @@
+/*
+ * File: client_test.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package client_test
+
+import (
+	"archive/zip"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digitorus/timestamp"
+	"github.com/zitelog/mrsign/client"
+	"github.com/zitelog/mrsign/dirhash"
+	"github.com/zitelog/mrsign/protocol"
+	"github.com/zitelog/mrsign/server"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return newTestServerAt(t, t.TempDir())
+}
+
+func newTestServerAt(t *testing.T, storePath string) *httptest.Server {
+	t.Helper()
+	// the stores written by the tests predate the ledger
+	s, err := server.NewServer(&server.Config{ServerStoreFilePath: storePath, LedgerInit: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestSignThenVerify(t *testing.T) {
+	ts := newTestServer(t)
+	dir := t.TempDir()
+	evidence := filepath.Join(dir, "evidence.txt")
+	if err := os.WriteFile(evidence, []byte("acquisition"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := client.NewClient(ts.URL, dir, "", "")
+	c.SetManifest(true)
+	if c.Exists() {
+		t.Fatal("store exists before signing")
+	}
+	if err := c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Exists() {
+		t.Fatal("store missing after signing")
+	}
+	body, err := os.ReadFile(filepath.Join(dir, client.ClientStoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := client.NewClientStore()
+	if err = json.Unmarshal(body, store); err != nil {
+		t.Fatal(err)
+	}
+	if !protocol.ValidHex128(store.Receipt.SID) || len(store.Receipt.Digest) != 64 {
+		t.Errorf("invalid receipt %+v", store.Receipt)
+	}
+	if store.Symlinks != dirhash.SymlinkFollowAll {
+		t.Errorf("symlink policy %q, want the default recorded", store.Symlinks)
+	}
+	if err := c.Restore(); err != nil {
+		t.Fatalf("verify after sign: %v", err)
+	}
+
+	err = client.NewClient(ts.URL, dir, "other.store", "").Generate("bob", "", "pc01")
+	if !errors.Is(err, client.ErrConflict) {
+		t.Errorf("second signature of the same folder: %v", err)
+	}
+
+	if err := os.WriteFile(evidence, []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = c.Restore()
+	var se *client.StatusError
+	if !errors.Is(err, client.ErrMismatch) || !errors.As(err, &se) {
+		t.Fatalf("verify of a modified folder: %v", err)
+	}
+	if se.Code != protocol.ErrorMismatch || se.Message != "different signature" || len(se.RequestID) != 32 {
+		t.Errorf("error response %+v", se)
+	}
+	d, err := c.Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Modified) != 1 || d.Modified[0] != "evidence.txt" {
+		t.Errorf("Modified = %v", d.Modified)
+	}
+}
+
+func TestVerifyUnknownFolder(t *testing.T) {
+	ts := newTestServer(t)
+	dir := t.TempDir()
+	c := client.NewClient(ts.URL, dir, "", "")
+	if err := c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+
+	other := newTestServer(t)
+	err := client.NewClient(other.URL, dir, "", "").Restore()
+	if !errors.Is(err, client.ErrNotFound) || errors.Is(err, client.ErrMismatch) {
+		t.Errorf("verify against a server without the signature: %v", err)
+	}
+}
+
+// legacySignature stores the signature of dir made before the MAC
+// negotiation, computed as the server used to compute it over the folder hash
+// returned by hash, and writes its client store, with the receipt when
+// receipt. The store without receipt is written before hashing, as the first
+// client did. It returns the server store path.
+func legacySignature(t *testing.T, dir string, hash func() string, receipt bool) string {
+	t.Helper()
+	nm := protocol.NewMessageNegotiate()
+	nm.UserName = "BOB"
+	nm.HostName = "PC01"
+	nm.FolderName = dir
+	legacy := server.ServerStore{
+		Key:             nm.CreateKey(),
+		User:            nm.UserName,
+		HostName:        nm.HostName,
+		Path:            dir,
+		Timestamp:       "a1cb5a32ec5ddd01",
+		ServerChallenge: strings.Repeat("ab", 64),
+	}
+
+	store := client.NewClientStore()
+	store.User = "bob"
+	store.HostName = "pc01"
+	store.Path = dir
+	store.ClientChallenge = nm.ClientChallenge
+	writeStore := func() {
+		body, _ := json.Marshal(store)
+		if err := os.WriteFile(filepath.Join(dir, client.ClientStoreFile), body, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !receipt {
+		writeStore()
+	}
+
+	hasher := protocol.NewHasherZ()
+	folderHash := hasher.CreateHash([]byte(hash()), nm.UserName, nm.HostName, nm.FolderName)
+	legacy.Result = hasher.CreateResponse(folderHash, []byte(legacy.ServerChallenge), []byte(nm.ClientChallenge), []byte(legacy.Timestamp))
+	if receipt {
+		legacy.SID = protocol.Hex128(protocol.NextUUID())
+		store.Receipt = legacy.Receipt()
+		writeStore()
+	}
+
+	storePath := t.TempDir()
+	js, err := server.NewJsonStore(filepath.Join(storePath, server.ServerStoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = js.Put(legacy.Key, legacy); err != nil {
+		t.Fatal(err)
+	}
+	return storePath
+}
+
+// hashDir returns the folder hash of dir without the excluded names
+func hashDir(t *testing.T, dir string, exclude []string) func() string {
+	return func() string {
+		h, err := dirhash.HashDir(dir, "", exclude, dirhash.Hash256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+}
+
+func TestVerifyLegacyMD5Signature(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "evidence.txt"), []byte("acquisition"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ts := newTestServerAt(t, legacySignature(t, dir, hashDir(t, dir, []string{client.ClientStoreFile}), true))
+	if err := client.NewClient(ts.URL, dir, "", "").Restore(); err != nil {
+		t.Fatalf("legacy signature: %v", err)
+	}
+}
+
+func TestVerifyBaselineSignature(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "evidence.txt"), []byte("acquisition"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the first client excluded the server store path, which matches no
+	// name: its own store is part of the folder hash
+	serverStore := string(os.PathSeparator) + client.ClientStoreFile
+	ts := newTestServerAt(t, legacySignature(t, dir, hashDir(t, dir, []string{serverStore}), false))
+	if err := client.NewClient(ts.URL, dir, "", "").Restore(); err != nil {
+		t.Fatalf("baseline signature: %v", err)
+	}
+}
+
+func TestSignatureMac(t *testing.T) {
+	ts := newTestServer(t)
+	dir := t.TempDir()
+	c := client.NewClient(ts.URL, dir, "", "")
+	if err := c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+	body, err := os.ReadFile(filepath.Join(dir, client.ClientStoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := client.NewClientStore()
+	if err = json.Unmarshal(body, store); err != nil {
+		t.Fatal(err)
+	}
+	if store.Receipt.Algorithm != protocol.MacSHA512 {
+		t.Errorf("got mac %q, want %q", store.Receipt.Algorithm, protocol.MacSHA512)
+	}
+}
+
+func TestVerifyOffline(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), server.DefaultSigningKeyFile)
+	pub, err := server.GenerateSigningKey(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &server.Config{ServerStoreFilePath: t.TempDir()}
+	cfg.Signing.Enable = true
+	cfg.Signing.Key = keyFile
+	s, err := server.NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	dir := t.TempDir()
+	evidence := filepath.Join(dir, "evidence.txt")
+	if err = os.WriteFile(evidence, []byte("acquisition"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := client.NewClient(ts.URL, dir, "", "")
+	if err = c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+
+	served, _, err := c.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !served.Equal(pub) {
+		t.Error("served public key differs from the signing key")
+	}
+
+	// offline verification must not need the server
+	ts.Close()
+	if err = c.VerifyOffline(pub); err != nil {
+		t.Fatalf("offline verify: %v", err)
+	}
+
+	other, err := server.GenerateSigningKey(filepath.Join(t.TempDir(), server.DefaultSigningKeyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.VerifyOffline(other); err == nil {
+		t.Error("offline verify accepted a receipt with another key")
+	}
+
+	if err = os.WriteFile(evidence, []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.VerifyOffline(pub); err == nil {
+		t.Error("offline verify accepted a modified folder")
+	}
+}
+
+// newTestTSA starts a stand-in TSA and returns it with the file of its
+// certificate
+func newTestTSA(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test tsa"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(t.TempDir(), "tsa.pem")
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req, err := timestamp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tst := timestamp.Timestamp{
+			HashAlgorithm:     req.HashAlgorithm,
+			HashedMessage:     req.HashedMessage,
+			Time:              time.Now(),
+			Nonce:             req.Nonce,
+			Policy:            []int{1, 2, 3, 4, 1},
+			AddTSACertificate: req.Certificates,
+		}
+		res, err := tst.CreateResponseWithOpts(cert, key, crypto.SHA256)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/timestamp-reply")
+		_, _ = w.Write(res)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, certFile
+}
+
+func TestTimestampToken(t *testing.T) {
+	tsa, tsaCert := newTestTSA(t)
+	keyFile := filepath.Join(t.TempDir(), server.DefaultSigningKeyFile)
+	pub, err := server.GenerateSigningKey(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storePath := t.TempDir()
+	cfg := &server.Config{ServerStoreFilePath: storePath}
+	cfg.Signing.Enable = true
+	cfg.Signing.Key = keyFile
+	cfg.TSA.Enable = true
+	cfg.TSA.Url = tsa.URL
+	cfg.TSA.Cert = tsaCert
+	s, err := server.NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	dir := t.TempDir()
+	c := client.NewClient(ts.URL, dir, "", "")
+	if err = c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Restore(); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	roots, err := protocol.LoadCertPool(tsaCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.VerifyOffline(pub); err != nil || c.Timestamp() != client.TimestampUntrusted {
+		t.Fatalf("offline verify without TSA certificates = %v, timestamp %q", err, c.Timestamp())
+	}
+	c.SetTSARoots(roots)
+	if err = c.VerifyOffline(pub); err != nil || c.Timestamp() != client.TimestampTrusted {
+		t.Fatalf("offline verify = %v, timestamp %q", err, c.Timestamp())
+	}
+
+	_, otherCert := newTestTSA(t)
+	others, err := protocol.LoadCertPool(otherCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetTSARoots(others)
+	if err = c.VerifyOffline(pub); err == nil {
+		t.Error("offline verify accepted a token of an untrusted TSA")
+	}
+
+	// a token over another signature result must not verify
+	js, err := server.NewJsonStore(filepath.Join(storePath, server.ServerStoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := js.List()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("List() = %v, %v", entries, err)
+	}
+	tsc, err := server.NewTSAClient(tsa.URL, tsaCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].TimestampToken, err = tsc.Timestamp([]byte("other result")); err != nil {
+		t.Fatal(err)
+	}
+	if err = js.Put(entries[0].Key, entries[0]); err != nil {
+		t.Fatal(err)
+	}
+	ts2 := newTestServerAt(t, storePath)
+	if err = client.NewClient(ts2.URL, dir, "", "").Restore(); err == nil {
+		t.Error("verify accepted a token over another signature")
+	}
+}
+
+func newSigningServer(t *testing.T) (*httptest.Server, ed25519.PublicKey) {
+	t.Helper()
+	keyFile := filepath.Join(t.TempDir(), server.DefaultSigningKeyFile)
+	pub, err := server.GenerateSigningKey(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &server.Config{ServerStoreFilePath: t.TempDir()}
+	cfg.Signing.Enable = true
+	cfg.Signing.Key = keyFile
+	s, err := server.NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return ts, pub
+}
+
+func TestFileProof(t *testing.T) {
+	ts, pub := newSigningServer(t)
+	var err error
+
+	dir := t.TempDir()
+	for name, data := range map[string]string{"a.txt": "hello\n", "sub/b.txt": "world\n", "sub/c.txt": "!"} {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := client.NewClient(ts.URL, dir, "", "")
+	c.SetManifest(true)
+	c.SetHash(dirhash.SchemeM1)
+	if err = c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+	if err = client.NewClient(ts.URL, dir, "", "").Restore(); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	proof, err := c.Prove(filepath.Join(dir, "sub", "b.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(proof)
+	var got client.FileProof
+	if err = json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err = client.VerifyFileProof(&got, strings.NewReader("world\n"), pub); err != nil {
+		t.Fatalf("proof: %v", err)
+	}
+	if err = client.VerifyFileProof(&got, strings.NewReader("tampered"), pub); err == nil {
+		t.Error("proof accepted a modified file")
+	}
+	other, err := server.GenerateSigningKey(filepath.Join(t.TempDir(), server.DefaultSigningKeyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.VerifyFileProof(&got, strings.NewReader("world\n"), other); err == nil {
+		t.Error("proof accepted a receipt of another key")
+	}
+
+	if _, err = c.Prove("missing.txt"); err == nil {
+		t.Error("proof of a file outside the signature")
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	ts := newTestServer(t)
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		dirhash.IgnoreFile:  "# scratch files\n*.tmp\n",
+		"evidence.txt":      "acquisition",
+		"scratch.tmp":       "x",
+		"cache/index":       "x",
+		"sub/zclient.store": "an evidence file named as the client store",
+	} {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := client.NewClient(ts.URL, dir, "", "")
+	c.SetPatterns(nil, []string{"cache/"})
+	if err := c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+	body, err := os.ReadFile(filepath.Join(dir, client.ClientStoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := client.NewClientStore()
+	if err = json.Unmarshal(body, store); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"*.tmp", "cache/", "/" + client.ClientStoreFile, "/zclient" + client.ClientManifestExt}
+	if store.Patterns == nil || strings.Join(store.Patterns.Exclude, " ") != strings.Join(want, " ") {
+		t.Fatalf("patterns = %+v, want exclude %q", store.Patterns, want)
+	}
+
+	// the recorded patterns apply, not the ones of the client
+	for _, name := range []string{"scratch.tmp", "cache/index"} {
+		if err = os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte("changed"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = client.NewClient(ts.URL, dir, "", "").Restore(); err != nil {
+		t.Fatalf("verify after changing excluded files: %v", err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "sub", "zclient.store"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Restore(); err == nil {
+		t.Error("verify accepted a modified file named as the client store")
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	ts := newTestServer(t)
+	dir := t.TempDir()
+	evidence := filepath.Join(dir, "evidence.txt")
+	if err := os.WriteFile(evidence, []byte("acquisition"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := client.NewClient(ts.URL, dir, "", "")
+	c.SetMetadata(true)
+	if err := c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Restore(); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	// the hash mode comes from the signature, not from the verifying client
+	storeFile := filepath.Join(dir, client.ClientStoreFile)
+	body, err := os.ReadFile(storeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := client.NewClientStore()
+	if err = json.Unmarshal(body, store); err != nil {
+		t.Fatal(err)
+	}
+	if !store.Metadata {
+		t.Fatal("metadata mode not recorded in the client store")
+	}
+	store.Metadata = false
+	changed, _ := json.Marshal(store)
+	if err = os.WriteFile(storeFile, changed, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Restore(); err == nil || !strings.Contains(err.Error(), "different hash mode") {
+		t.Errorf("verify without metadata = %v", err)
+	}
+	if err = os.WriteFile(storeFile, body, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.Chmod(evidence, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Restore(); err == nil {
+		t.Error("verify accepted a chmod'ed file")
+	}
+}
+
+func TestSignFile(t *testing.T) {
+	ts := newTestServer(t)
+	dir := t.TempDir()
+	image := filepath.Join(dir, "disk.E01")
+	if err := os.WriteFile(image, []byte("raw image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := client.NewClient(ts.URL, image, "", "")
+	c.SetManifest(true)
+	if err := c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+	for _, sidecar := range []string{"disk.E01.zclient.store", "disk.E01.zclient.manifest"} {
+		if _, err := os.Stat(filepath.Join(dir, sidecar)); err != nil {
+			t.Errorf("sidecar %s: %v", sidecar, err)
+		}
+	}
+	if err := client.NewClient(ts.URL, image, "", "").Restore(); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if err := os.WriteFile(image, []byte("raw imagf"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Restore(); err == nil {
+		t.Error("verify accepted a modified file")
+	}
+
+	c = client.NewClient(ts.URL, dir, "folder.store", "")
+	c.SetArchive(true)
+	if err := c.Generate("bob", "", "pc01"); err == nil {
+		t.Error("folder signed as an archive")
+	}
+}
+
+func TestSignArchive(t *testing.T) {
+	ts, key := newSigningServer(t)
+	dir := t.TempDir()
+	name := filepath.Join(dir, "acquisition.zip")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, entry := range []string{"b.txt", "a.txt"} {
+		w, err := zw.Create(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(entry))
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	c := client.NewClient(ts.URL, name, "", "")
+	c.SetArchive(true)
+	c.SetHash(dirhash.SchemeM1)
+	if err = c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Restore(); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	p, err := c.Prove("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.VerifyFileProof(p, strings.NewReader("a.txt"), key); err != nil {
+		t.Errorf("entry proof: %v", err)
+	}
+
+	// the same entries written again, in another order, verify
+	f, err = os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw = zip.NewWriter(f)
+	for _, entry := range []string{"a.txt", "b.txt"} {
+		w, _ := zw.Create(entry)
+		_, _ = w.Write([]byte(entry))
+	}
+	_ = zw.Close()
+	_ = f.Close()
+	if err = client.NewClient(ts.URL, name, "", "").Restore(); err != nil {
+		t.Errorf("verify the rewritten archive: %v", err)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	ts := newTestServer(t)
+	dir := t.TempDir()
+	evidence := filepath.Join(dir, "evidence.txt")
+	if err := os.WriteFile(evidence, []byte("acquisition"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bundle := filepath.Join(t.TempDir(), "evidence"+client.BundleExt)
+
+	c := client.NewClient(ts.URL, dir, "", "")
+	c.SetBundle(bundle)
+	c.SetManifest(true)
+	if err := c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("signing wrote in the evidence folder: %d files", len(entries))
+	}
+	b, err := client.ReadBundle(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Store.Path != dir || len(b.Store.Receipt.Key) == 0 || b.Manifest == nil || len(b.Manifest.Files) != 1 {
+		t.Fatalf("bundle %+v", b)
+	}
+
+	v := client.NewClient(ts.URL, dir, "", "")
+	v.SetBundle(bundle)
+	if err = v.Restore(); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if client.NewClient(ts.URL, dir, "", "").Exists() {
+		t.Error("client store found without the bundle")
+	}
+	if err = os.WriteFile(evidence, []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Restore(); err == nil {
+		t.Error("verify accepted a modified folder")
+	}
+	d, err := v.Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Modified) != 1 || d.Modified[0] != "evidence.txt" {
+		t.Errorf("diff %+v", d)
+	}
+
+	// a bundle saved in the folder is not hashed
+	dir = t.TempDir()
+	if err = os.WriteFile(filepath.Join(dir, "evidence.txt"), []byte("acquisition"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	inside := filepath.Join(dir, "inside"+client.BundleExt)
+	c = client.NewClient(ts.URL, dir, "", "")
+	c.SetBundle(inside)
+	if err = c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Restore(); err != nil {
+		t.Errorf("verify with the bundle in the folder: %v", err)
+	}
+}
+
+func TestBundleSupersede(t *testing.T) {
+	ts := newTestServer(t)
+	dir := t.TempDir()
+	evidence := filepath.Join(dir, "evidence.txt")
+	if err := os.WriteFile(evidence, []byte("acquisition"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bundle := filepath.Join(t.TempDir(), "evidence"+client.BundleExt)
+	c := client.NewClient(ts.URL, dir, "", "")
+	c.SetBundle(bundle)
+	c.SetManifest(true)
+	if err := c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new revision without manifest drops the one of the first revision
+	if err := os.WriteFile(evidence, []byte("acquisition, redone"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c = client.NewClient(ts.URL, dir, "", "")
+	c.SetBundle(bundle)
+	c.SetSupersede("acquisition redone")
+	if err := c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := client.ReadBundle(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Store.Receipt.Revision != 2 || b.Manifest != nil {
+		t.Fatalf("revision %d with manifest %+v", b.Store.Receipt.Revision, b.Manifest)
+	}
+
+	// and a new one with manifest saves its own
+	if err = os.WriteFile(evidence, []byte("acquisition, redone twice"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c.SetManifest(true)
+	if err = c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+	v := client.NewClient(ts.URL, dir, "", "")
+	v.SetBundle(bundle)
+	if err = v.Restore(); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	d, err := v.Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Added)+len(d.Removed)+len(d.Modified) > 0 {
+		t.Errorf("diff against the manifest of the latest revision %+v", d)
+	}
+}
+
+func TestSupersede(t *testing.T) {
+	ts, key := newSigningServer(t)
+	dir := t.TempDir()
+	evidence := filepath.Join(dir, "evidence.txt")
+	if err := os.WriteFile(evidence, []byte("acquisition"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := client.NewClient(ts.URL, dir, "", "")
+	c.SetSupersede("nothing signed yet")
+	if err := c.Generate("bob", "", "pc01"); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("supersede without signature: %v", err)
+	}
+	if c.Exists() {
+		t.Fatal("client store left after a failed signature")
+	}
+	c.SetSupersede("")
+	if err := c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+	first, err := c.Store()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(evidence, []byte("acquisition, second extraction"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = client.NewClient(ts.URL, dir, "", "").Generate("bob", "", "pc01"); !errors.Is(err, client.ErrConflict) {
+		t.Fatalf("second signature without supersede: %v", err)
+	}
+	c.SetSupersede("second extraction")
+	if err = c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Store()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := second.Receipt
+	if r.Revision != 2 || r.Supersedes != first.Receipt.SID || r.Reason != "second extraction" || r.Key != first.Receipt.Key {
+		t.Fatalf("receipt %+v", r)
+	}
+	if len(second.Previous) != 1 || second.Previous[0].Receipt.SID != first.Receipt.SID {
+		t.Fatalf("previous revisions %+v", second.Previous)
+	}
+
+	v := client.NewClient(ts.URL, dir, "", "")
+	if err = v.Restore(); err != nil {
+		t.Fatalf("verify the latest revision: %v", err)
+	}
+	if v.Latest() == nil || v.Latest().SID != r.SID {
+		t.Errorf("latest %+v", v.Latest())
+	}
+	if err = v.VerifyOffline(key); err != nil {
+		t.Errorf("offline verify of the latest revision: %v", err)
+	}
+
+	v.SetRevision(1)
+	if err = v.Restore(); !errors.Is(err, client.ErrMismatch) {
+		t.Errorf("verify the first revision of a modified folder: %v", err)
+	}
+	if err = os.WriteFile(evidence, []byte("acquisition"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = v.Restore(); err != nil {
+		t.Fatalf("verify the first revision: %v", err)
+	}
+	if v.Latest().RevisionNumber() != 2 {
+		t.Errorf("latest revision %d", v.Latest().RevisionNumber())
+	}
+	v.SetRevision(3)
+	if err = v.Restore(); err == nil {
+		t.Error("verify of a missing revision succeeded")
+	}
+
+	// the revision and the reason are signed
+	r.Reason = "forged"
+	if err = r.VerifySignature(key); err == nil {
+		t.Error("receipt with a modified reason verified")
+	}
+}
+
+func TestSignatures(t *testing.T) {
+	s, err := server.NewServer(&server.Config{
+		ServerStoreFilePath: t.TempDir(),
+		Users:               server.UsersConfig{Enable: true, Accounts: []server.UserConfig{{User: "op", Hash: protocol.GenerateHash("secret")}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	authUrl := strings.Replace(ts.URL, "http://", "http://op:secret@", 1)
+
+	var sids []string
+	for _, user := range []string{"bob", "alice"} {
+		dir := t.TempDir()
+		if err = os.WriteFile(filepath.Join(dir, "evidence.txt"), []byte(user), 0644); err != nil {
+			t.Fatal(err)
+		}
+		c := client.NewClient(authUrl, dir, "", "")
+		if err = c.Generate(user, "", "pc01"); err != nil {
+			t.Fatal(err)
+		}
+		store, err := c.Store()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sids = append(sids, store.Receipt.SID)
+	}
+
+	if _, err = client.NewClient(ts.URL, "", "", "").Signatures(protocol.SignatureQuery{}); !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("list without credentials: %v", err)
+	}
+	c := client.NewClient(authUrl, "", "", "")
+	l, err := c.Signatures(protocol.SignatureQuery{User: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Total != 1 || len(l.Signatures) != 1 || l.Signatures[0].SID != sids[1] || l.Limit != protocol.DefaultSignatureLimit {
+		t.Fatalf("list %+v", l)
+	}
+	info, err := c.Signature(sids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.User != "BOB" || info.Revision != 1 || info.Signed.IsZero() {
+		t.Errorf("signature %+v", info)
+	}
+	if _, err = c.Signature("missing"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("missing signature: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+protocol.ApiSignatures, nil)
+	req.SetBasicAuth("op", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	for _, field := range []string{`"result"`, `"serverChallenge"`} {
+		if strings.Contains(string(body), field) {
+			t.Errorf("listing exposes %s: %s", field, body)
+		}
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	cfg := &server.Config{
+		ServerStoreFilePath: t.TempDir(),
+		Users: server.UsersConfig{Enable: true, Accounts: []server.UserConfig{
+			{User: "op", Hash: protocol.GenerateHash("secret")},
+			{User: "other", Hash: protocol.GenerateHash("secret")},
+			{User: "chief", Hash: protocol.GenerateHash("secret"), Admin: true},
+		}},
+	}
+	s, err := server.NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	authUrl := strings.Replace(ts.URL, "http://", "http://op:secret@", 1)
+
+	dir := t.TempDir()
+	evidence := filepath.Join(dir, "evidence.txt")
+	if err = os.WriteFile(evidence, []byte("acquisition"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := client.NewClient(authUrl, dir, "", "")
+	if err = c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+	first, err := c.Store()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(evidence, []byte("acquisition, redone"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c.SetSupersede("acquisition redone")
+	if err = c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = c.Revoke(first.Receipt.SID, "botched", ""); err == nil {
+		t.Error("revocation with an unknown reason accepted")
+	}
+	if _, err = client.NewClient(ts.URL, "", "", "").Revoke(first.Receipt.SID, protocol.RevokeAcquisitionError, ""); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("revocation without credentials: %v", err)
+	}
+	other := client.NewClient(strings.Replace(ts.URL, "http://", "http://other:secret@", 1), dir, "", "")
+	if _, err = other.Revoke(first.Receipt.SID, protocol.RevokeAcquisitionError, ""); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("revocation by another account: %v", err)
+	}
+	other.SetSupersede("taken over")
+	if err = other.Generate("bob", "", "pc01"); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("new revision by another account: %v", err)
+	}
+	if list, err := c.Signatures(protocol.SignatureQuery{History: true}); err != nil || list.Total != 2 {
+		t.Errorf("signatures after the refused revision %+v, %v", list, err)
+	}
+	info, err := c.Revoke(first.Receipt.SID, protocol.RevokeAcquisitionError, "write blocker missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := info.Revocation
+	if r == nil || r.By != "op" || r.Reason != protocol.RevokeAcquisitionError || r.Comment != "write blocker missing" || r.Time.IsZero() {
+		t.Fatalf("revocation %+v", r)
+	}
+	if _, err = c.Revoke(first.Receipt.SID, protocol.RevokeUnspecified, ""); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("second revocation: %v", err)
+	}
+	if info, err = c.Signature(first.Receipt.SID); err != nil || info.Revocation == nil {
+		t.Errorf("revoked signature %+v, %v", info, err)
+	}
+
+	// the latest revision is still valid, the first one is revoked even
+	// for a matching folder
+	if err = c.Restore(); err != nil {
+		t.Errorf("verify the latest revision: %v", err)
+	}
+	if err = os.WriteFile(evidence, []byte("acquisition"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c.SetRevision(1)
+	if err = c.Restore(); !errors.Is(err, client.ErrRevoked) {
+		t.Errorf("verify the revoked revision: %v", err)
+	}
+
+	chief := client.NewClient(strings.Replace(ts.URL, "http://", "http://chief:secret@", 1), "", "", "")
+	latest, err := c.Store()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err = chief.Revoke(latest.Receipt.SID, protocol.RevokeCredentialCompromise, ""); err != nil || info.Revocation.By != "chief" {
+		t.Errorf("revocation by an admin %+v, %v", info, err)
+	}
+
+	if _, err = server.VerifyLedger(cfg, nil); err != nil {
+		t.Errorf("ledger after the revocation: %v", err)
+	}
+}
+
+func TestLedgerHead(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), server.DefaultSigningKeyFile)
+	pub, err := server.GenerateSigningKey(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &server.Config{ServerStoreFilePath: t.TempDir()}
+	cfg.Signing.Enable = true
+	cfg.Signing.Key = keyFile
+	s, err := server.NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	c := client.NewClient(ts.URL, t.TempDir(), "", "")
+	if err = c.Generate("bob", "", "pc01"); err != nil {
+		t.Fatal(err)
+	}
+	head, err := c.LedgerHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = head.VerifySignature(pub); err != nil {
+		t.Fatal(err)
+	}
+	anchor := server.LedgerHead{Seq: head.Seq, Hash: head.Hash}
+	if _, err = server.VerifyLedger(cfg, &anchor); err != nil || head.Seq != 1 {
+		t.Fatalf("VerifyLedger() at head %d = %v", head.Seq, err)
+	}
+	head.Seq++
+	if err = head.VerifySignature(pub); err == nil {
+		t.Error("altered ledger head verified")
+	}
+}
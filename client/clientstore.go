@@ -0,0 +1,100 @@
+/*
+ * File: clientStore.go
+ * Project: mrsign
+ * Created Date: Tuesday, October 19th 2021, 12:16:54 pm
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package client
+
+import (
+	"time"
+
+	"github.com/zitelog/mrsign/dirhash"
+	"github.com/zitelog/mrsign/protocol"
+)
+
+const ClientStoreFile = "zclient.store"
+const ClientManifestExt = ".manifest"
+
+// Signed targets, a folder when empty
+const (
+	// TargetFile is a single file, the store is a sidecar
+	TargetFile = "file"
+	// TargetArchive is the entries of a zip or tar archive
+	TargetArchive = "archive"
+)
+
+type ClientStore struct {
+	User            string            `json:"user"`
+	HostName        string            `json:"hostName"`
+	Path            string            `json:"path"`
+	ClientChallenge string            `json:"clientChallenge"`
+	Epoch           int64             `json:"epoch"`
+	Target          string            `json:"target,omitempty"`
+	Hash            string            `json:"hash,omitempty"`
+	Metadata        bool              `json:"metadata,omitempty"`
+	Symlinks        string            `json:"symlinks,omitempty"`
+	Special         string            `json:"special,omitempty"`
+	Patterns        *dirhash.Patterns `json:"patterns,omitempty"`
+	Manifest        string            `json:"manifest,omitempty"`
+	Receipt         protocol.Receipt  `json:"receipt"`
+	// Previous are the stores of the superseded revisions, oldest first
+	Previous []ClientStore `json:"previous,omitempty"`
+}
+
+func NewClientStore() *ClientStore {
+	return &ClientStore{
+		User:            "",
+		HostName:        "",
+		Path:            "",
+		ClientChallenge: "",
+		Epoch:           time.Now().UnixNano() / int64(time.Millisecond),
+	}
+}
+
+// History returns the stores of every revision of the signature, oldest first
+func (s *ClientStore) History() []ClientStore {
+	latest := *s
+	latest.Previous = nil
+	return append(append([]ClientStore{}, s.Previous...), latest)
+}
+
+// AtRevision returns the store of the revision n of the signature
+func (s *ClientStore) AtRevision(n int) (*ClientStore, bool) {
+	for _, r := range s.History() {
+		if r.Receipt.RevisionNumber() == n {
+			return &r, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,117 @@
+/*
+ * File: errors.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/zitelog/mrsign/protocol"
+)
+
+// Errors of the server answers, to be checked with errors.Is
+var (
+	ErrMismatch     = errors.New("signature mismatch")
+	ErrNotFound     = errors.New("signature not found")
+	ErrConflict     = errors.New("signature already exists")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrRevoked      = errors.New("signature revoked")
+)
+
+// StatusError is an answer of the server other than 200 OK. Code is the
+// machine code of the error, empty when the server answers with plain text.
+type StatusError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestID  string
+}
+
+func newStatusError(resp *http.Response, body []byte) *StatusError {
+	e := &StatusError{StatusCode: resp.StatusCode, RequestID: resp.Header.Get(protocol.HeaderRequestID)}
+	var er protocol.ErrorResponse
+	if json.Unmarshal(body, &er) == nil && len(er.Code) > 0 {
+		e.Code = er.Code
+		e.Message = er.Message
+		if len(er.RequestID) > 0 {
+			e.RequestID = er.RequestID
+		}
+	} else {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	return e
+}
+
+func (e *StatusError) Error() string {
+	if len(e.Code) == 0 {
+		return "invalid status code: " + e.Message
+	}
+	return e.Message
+}
+
+// Is matches the Err sentinel of the error code, or of the status code for
+// the servers without error codes
+func (e *StatusError) Is(target error) bool {
+	switch e.Code {
+	case protocol.ErrorMismatch:
+		return target == ErrMismatch
+	case protocol.ErrorNotFound:
+		return target == ErrNotFound
+	case protocol.ErrorConflict:
+		return target == ErrConflict
+	case protocol.ErrorUnauthorized, protocol.ErrorForbidden:
+		return target == ErrUnauthorized
+	case protocol.ErrorRevoked:
+		return target == ErrRevoked
+	case "":
+		switch e.StatusCode {
+		case http.StatusForbidden:
+			return target == ErrMismatch
+		case http.StatusNotFound:
+			return target == ErrNotFound
+		case http.StatusConflict:
+			return target == ErrConflict
+		case http.StatusUnauthorized:
+			return target == ErrUnauthorized
+		case http.StatusGone:
+			return target == ErrRevoked
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+/*
+ * File: fileproof.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package client
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/zitelog/mrsign/dirhash"
+	"github.com/zitelog/mrsign/protocol"
+)
+
+// FileProof proves that a file belonged to a signed folder without the other
+// files: the inclusion proof leads to the folder hash, which the signed
+// receipt binds to the signature
+type FileProof struct {
+	Hash            string              `json:"hash"`
+	ClientChallenge string              `json:"clientChallenge"`
+	Proof           dirhash.MerkleProof `json:"proof"`
+	Receipt         protocol.Receipt    `json:"receipt"`
+}
+
+// Prove returns the proof of file, a path in the folder or relative to it,
+// or the name of an entry of a signed archive. The folder must be signed with
+// the Merkle hash and be unchanged.
+func (c *Client) Prove(file string) (*FileProof, error) {
+	store, err := c.loadStore()
+	if err != nil {
+		return nil, err
+	}
+	if store.Hash != dirhash.SchemeM1 {
+		return nil, errors.New("the folder is not signed with the merkle hash")
+	}
+	if len(store.Receipt.ServerChallenge) == 0 {
+		return nil, errors.New("receipt does not support offline verification")
+	}
+	switch store.Target {
+	case TargetFile:
+		file = filepath.Base(c.path)
+	case TargetArchive:
+	default:
+		if filepath.IsAbs(file) {
+			if file, err = filepath.Rel(c.path, file); err != nil {
+				return nil, err
+			}
+		}
+		file = filepath.ToSlash(filepath.Clean(file))
+		if strings.HasPrefix(file, "../") {
+			return nil, errors.New("file outside the signed folder: " + file)
+		}
+	}
+
+	m, err := c.folderManifest(store)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := m.MerkleTree()
+	if err != nil {
+		return nil, err
+	}
+	hash := tree.Hash()
+	if err = store.Receipt.VerifyResult(hash, store.ClientChallenge); err != nil {
+		return nil, err
+	}
+	proof, err := tree.Proof(file)
+	if err != nil {
+		return nil, err
+	}
+	return &FileProof{
+		Hash:            hash,
+		ClientChallenge: store.ClientChallenge,
+		Proof:           *proof,
+		Receipt:         store.Receipt,
+	}, nil
+}
+
+// VerifyFileProof checks that r is the file of the proof, that the file
+// belonged to the signed folder and that the receipt is signed by key
+func VerifyFileProof(p *FileProof, r io.Reader, key ed25519.PublicKey) error {
+	if err := p.Receipt.VerifySignature(key); err != nil {
+		return err
+	}
+	if err := p.Receipt.VerifyResult(p.Hash, p.ClientChallenge); err != nil {
+		return err
+	}
+	return p.Proof.VerifyFile(r, p.Hash)
+}
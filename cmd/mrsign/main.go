@@ -0,0 +1,1191 @@
+/*
+ * File: main.go
+ * Project: mrsign
+ * Created Date: Tuesday, October 19th 2021, 12:16:54 pm
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package main
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zitelog/mrsign/client"
+	"github.com/zitelog/mrsign/dirhash"
+	"github.com/zitelog/mrsign/logging"
+	"github.com/zitelog/mrsign/protocol"
+	"github.com/zitelog/mrsign/server"
+)
+
+const defaultPort = "8123"
+const defaultServer = "127.0.0.1:" + defaultPort
+const defaultUrl = "http://" + defaultServer
+
+// Exit codes
+const (
+	exitOK = 0
+	// exitMismatch: the signature does not match, or differences were found
+	exitMismatch = 1
+	exitUsage    = 2
+	// exitNotFound: no signature for the client path
+	exitNotFound = 3
+	// exitConflict: the client path is already signed
+	exitConflict = 4
+	// exitError: any other error (I/O, invalid files...)
+	exitError = 5
+	// exitUnauthorized: the server refused the credentials
+	exitUnauthorized = 6
+	// exitTransport: the server could not be reached
+	exitTransport = 7
+	// exitRevoked: the signature matches but has been revoked
+	exitRevoked = 8
+)
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string) int
+}
+
+var commands = []command{
+	{"server", "start the signature server, or prepare its keys", serverCmd},
+	{"sign", "sign a folder, a file or an archive", sign},
+	{"verify", "verify a signature, with the server or offline", verify},
+	{"diff", "list the files changed since the signature", diff},
+	{"list", "list and search the signatures of the server", list},
+	{"show", "show a signature and its receipt", show},
+	{"revoke", "revoke a signature of the server", revoke},
+	{"hash", "print the hash a signature would sign", hash},
+	{"proof", "prove a file part of a signed folder, or verify a proof", proof},
+	{"pubkey", "print the server public key", pubkey},
+	{"ledger", "verify the server ledger", ledger},
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: mrsign <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.description)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "mrsign <command> -h shows the flags of a command, mrsign -v the version")
+}
+
+// patternsFlag collects the values of a repeated flag
+type patternsFlag []string
+
+func (p *patternsFlag) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patternsFlag) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
+// newFlagSet returns the flags of a command, its help shows the arguments and
+// the description
+func newFlagSet(name string, arguments string, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: mrsign %s %s\n\n%s\n\nflags:\n", name, arguments, description)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses the flags of a command, ok is false when the command must exit
+// with code
+func parse(fs *flag.FlagSet, args []string) (code int, ok bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+// usageError prints the error and the help of the command
+func usageError(fs *flag.FlagSet, msg string) int {
+	fmt.Fprintln(fs.Output(), msg)
+	fs.Usage()
+	return exitUsage
+}
+
+// acquireFromStdin prompts with label on stderr, not to mix with the output,
+// and reads a line of stdin
+func acquireFromStdin(label string) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+	return readLine(os.Stdin)
+}
+
+// readLine reads a whole line, spaces included, without its line ending
+func readLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+// setupLogging sets the default logger, quiet lowers the default level to
+// error when logging to stderr
+func setupLogging(logFilePath string, logLevel string, quiet bool) (io.Closer, error) {
+	if len(logLevel) == 0 && len(logFilePath) == 0 && quiet {
+		logLevel = "error"
+	}
+	logger, logFile, err := logging.New(logFilePath, logLevel)
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(logger)
+	return logFile, nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// progressPrinter renders the hashing progress on stderr
+func progressPrinter() func(dirhash.Progress) {
+	finished := false
+	return func(p dirhash.Progress) {
+		if finished {
+			return
+		}
+		files := fmt.Sprintf("%d/%d", p.Files, p.TotalFiles)
+		if p.TotalFiles == dirhash.UnknownTotal {
+			files = fmt.Sprint(p.Files)
+		}
+		fmt.Fprintf(os.Stderr, "\rhashing %s files, %s/%s, ETA %s   ",
+			files, formatBytes(p.Bytes), formatBytes(p.TotalBytes), p.ETA().Round(time.Second))
+		if p.Done {
+			fmt.Fprintln(os.Stderr)
+			finished = true
+		}
+	}
+}
+
+// clientPath returns path, else the path signed in the bundle, else the
+// working directory
+func clientPath(path string, bundleFile string) string {
+	if len(path) > 0 {
+		return path
+	}
+	if len(bundleFile) > 0 {
+		if b, err := client.ReadBundle(bundleFile); err == nil && len(b.Store.Path) > 0 {
+			return b.Store.Path
+		}
+	}
+	path, _ = os.Getwd()
+	return path
+}
+
+// clientFlags are the flags of the commands working on a signature
+type clientFlags struct {
+	path            string
+	clientStoreFile string
+	bundleFile      string
+	logFilePath     string
+	logLevel        string
+}
+
+func (f *clientFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.path, "p", "", "client path, a folder or a file (default the working directory)")
+	fs.StringVar(&f.clientStoreFile, "f", "", "client store filename")
+	fs.StringVar(&f.bundleFile, "bundle", "", "detached signature bundle file")
+	fs.StringVar(&f.logFilePath, "l", "", "logfile path")
+	fs.StringVar(&f.logLevel, "ll", "", "log level: debug, info, warn, error (default error without logfile)")
+}
+
+// hashFlags are the flags of the folder hash
+type hashFlags struct {
+	hashScheme  string
+	metadata    bool
+	archive     bool
+	symlinks    string
+	special     string
+	include     patternsFlag
+	exclude     patternsFlag
+	concurrency int
+	progress    bool
+}
+
+func (f *hashFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.hashScheme, "hash", dirhash.SchemeH1, "folder hash: h1 (sha256 of the file list), m1 (merkle tree, allows file proofs) or h2 (any filename)")
+	fs.BoolVar(&f.metadata, "meta", false, "sign also the file modes, modification times, symbolic links and empty directories")
+	fs.BoolVar(&f.archive, "archive", false, "sign the entries of the zip or tar archive given with -p instead of its bytes")
+	fs.StringVar(&f.symlinks, "symlinks", "", "symbolic links: follow (in the path), follow-all, link (hash the target path) or skip (default follow-all, link with -meta)")
+	fs.StringVar(&f.special, "special", dirhash.SpecialFail, "sockets, FIFOs and devices: fail or skip")
+	fs.Var(&f.include, "include", "hash only the files matching the pattern, repeatable")
+	fs.Var(&f.exclude, "exclude", "do not hash the files matching the pattern (gitignore style), repeatable")
+	fs.IntVar(&f.concurrency, "j", 0, "number of files hashed at once (default all the CPUs)")
+	fs.BoolVar(&f.progress, "progress", false, "show the hashing progress")
+}
+
+func (f *hashFlags) apply(c *client.Client) {
+	c.SetHash(f.hashScheme)
+	c.SetMetadata(f.metadata)
+	c.SetArchive(f.archive)
+	c.SetPolicies(f.symlinks, f.special)
+	c.SetPatterns(f.include, f.exclude)
+	c.SetConcurrency(f.concurrency)
+	if f.progress {
+		c.SetProgress(progressPrinter())
+	}
+}
+
+// open sets up the logging and returns the client of the signature, close
+// the returned io.Closer when done
+func (f *clientFlags) open(url string, serverStoreFilePath string) (*client.Client, io.Closer, error) {
+	logFile, err := setupLogging(f.logFilePath, f.logLevel, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := client.NewClient(url, clientPath(f.path, f.bundleFile), f.clientStoreFile, serverStoreFilePath)
+	if len(f.bundleFile) > 0 {
+		c.SetBundle(f.bundleFile)
+	}
+	return c, logFile, nil
+}
+
+func printDiff(d *dirhash.ManifestDiff) {
+	for _, f := range d.Added {
+		fmt.Println("+", f)
+	}
+	for _, f := range d.Removed {
+		fmt.Println("-", f)
+	}
+	for _, f := range d.Modified {
+		fmt.Println("M", f)
+	}
+}
+
+func serverCmd(args []string) int {
+	var configFilePath string
+	var generateHash string
+	var generateKey bool
+	var generateSigningKey bool
+	var logFilePath string
+	var logLevel string
+	var ledgerInit bool
+
+	fs := newFlagSet("server", "[-c config file] [-ledger-init] [-k | -ks | -g password]",
+		"Starts the signature server. -k, -ks and -g prepare the config file and exit.")
+	fs.StringVar(&configFilePath, "c", "config.json", "config file")
+	fs.BoolVar(&generateKey, "k", false, "generate a self-signed TLS certificate and key in the Secure Cert and Key files of the config file")
+	fs.BoolVar(&generateSigningKey, "ks", false, "generate the receipt signing key in the Signing Key file of the config file")
+	fs.StringVar(&generateHash, "g", "", "print the hash of a password for the Users accounts of the config file (- to read a line of stdin)")
+	fs.StringVar(&logFilePath, "l", "", "logfile path, JSON lines rotated every 10 MB")
+	fs.StringVar(&logLevel, "ll", "", "log level: debug, info, warn, error (default info)")
+	fs.BoolVar(&ledgerInit, "ledger-init", false, "import into a missing or empty ledger the signatures of the store")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+
+	if len(generateHash) > 0 {
+		if generateHash == "-" {
+			var err error
+			if generateHash, err = acquireFromStdin("Enter password: "); err != nil {
+				fmt.Println(err.Error())
+				return exitError
+			}
+		}
+		fmt.Println(protocol.GenerateHash(generateHash))
+		return exitOK
+	}
+
+	cfg, _ := server.NewLoader().Load(configFilePath)
+	if len(cfg.Listen) == 0 {
+		cfg.Listen = defaultServer
+	}
+
+	if generateKey {
+		if len(cfg.Secure.Cert) == 0 {
+			cfg.Secure.Cert = server.DefaultCertFile
+		}
+		if len(cfg.Secure.Key) == 0 {
+			cfg.Secure.Key = server.DefaultKeyFile
+		}
+		hosts := []string{"localhost"}
+		if h, _, err := net.SplitHostPort(cfg.Listen); err == nil && len(h) > 0 {
+			hosts = append(hosts, h)
+		}
+		if err := server.GenerateKeyPair(cfg.Secure.Cert, cfg.Secure.Key, hosts); err != nil {
+			fmt.Println(err.Error())
+			return exitError
+		}
+		fmt.Println("certificate:", cfg.Secure.Cert)
+		fmt.Println("key:", cfg.Secure.Key)
+		return exitOK
+	}
+
+	if generateSigningKey {
+		if len(cfg.Signing.Key) == 0 {
+			cfg.Signing.Key = server.DefaultSigningKeyFile
+		}
+		key, err := server.GenerateSigningKey(cfg.Signing.Key)
+		if err != nil {
+			fmt.Println(err.Error())
+			return exitError
+		}
+		fmt.Println("signing key:", cfg.Signing.Key)
+		fmt.Println("key id:", protocol.PublicKeyID(key))
+		return exitOK
+	}
+
+	logFile, err := setupLogging(logFilePath, logLevel, false)
+	if err != nil {
+		fmt.Println(err.Error())
+		return exitError
+	}
+	defer logFile.Close()
+	logger := slog.Default()
+
+	fmt.Printf("starting server %s\n", cfg.Listen)
+	cfg.LedgerInit = ledgerInit
+	s, err := server.NewServer(cfg)
+	if err != nil {
+		logger.Error("server", "error", err.Error())
+		fmt.Println(err.Error())
+		return exitError
+	}
+	if err = s.Start(); err != nil {
+		logger.Error("server", "error", err.Error())
+		fmt.Println(err.Error())
+		return exitError
+	}
+	return exitOK
+}
+
+func sign(args []string) int {
+	var cf clientFlags
+	var hf hashFlags
+	var out output
+	var challengeUrl string
+	var user string
+	var host string
+	var serverStoreFilePath string
+	var manifest bool
+	var supersede string
+
+	fs := newFlagSet("sign", "-u user -t host [-p path] [-supersede reason] [flags]",
+		"Signs the client path with the server and saves the client store in it,\nnext to it for a file, or in the -bundle file. Fails if it is already signed,\nunless -supersede signs a new revision.")
+	cf.register(fs)
+	hf.register(fs)
+	out.register(fs, "sign")
+	fs.StringVar(&challengeUrl, "r", defaultUrl, "server url")
+	fs.StringVar(&user, "u", "", "client user")
+	fs.StringVar(&host, "t", "", "client host")
+	fs.StringVar(&serverStoreFilePath, "sp", "", "server path, left out of the hash when in the client path")
+	fs.BoolVar(&manifest, "m", false, "generate a file manifest with the signature")
+	fs.StringVar(&supersede, "supersede", "", "sign a new revision of the signature, for the given reason")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if code, ok := out.check(fs); !ok {
+		return code
+	}
+	if len(user) == 0 {
+		return out.usage(fs, "missing client user")
+	}
+	if len(host) == 0 {
+		return out.usage(fs, "missing client host")
+	}
+
+	res := &result{}
+	c, logFile, err := cf.open(challengeUrl, serverStoreFilePath)
+	if err != nil {
+		return out.fail(res, codeError, err)
+	}
+	defer logFile.Close()
+	hf.apply(c)
+	c.SetManifest(manifest)
+	c.SetSupersede(supersede)
+	res.Path = c.Path()
+
+	if c.Exists() && len(supersede) == 0 {
+		return out.fail(res, codeConflict, errors.New("already signed, use mrsign verify or sign -supersede"))
+	}
+	if err = c.Generate(user, "", host); err != nil {
+		return out.failErr(res, err, codeError)
+	}
+	res.FolderHash = c.FolderHash()
+	if err = setReceipt(res, c, 0); err != nil {
+		return out.fail(res, codeError, err)
+	}
+	return out.ok(res, "signed", "Signature generated")
+}
+
+// setReceipt copies the receipt of the signature of c to res, of its
+// revision when not 0
+func setReceipt(res *result, c *client.Client, revision int) error {
+	store, err := c.Store()
+	if err != nil {
+		return err
+	}
+	if r, ok := store.AtRevision(revision); ok {
+		store = r
+	}
+	res.SID = store.Receipt.SID
+	res.Key = store.Receipt.Key
+	res.Revision = store.Receipt.RevisionNumber()
+	if t, err := store.Receipt.Time(); err == nil {
+		res.Timestamp = &t
+	}
+	return nil
+}
+
+func verify(args []string) int {
+	var cf clientFlags
+	var out output
+	var challengeUrl string
+	var offline bool
+	var publicKeyFile string
+	var tsaCertFile string
+	var concurrency int
+	var showProgress bool
+	var revision int
+
+	fs := newFlagSet("verify", "[-p path] [-revision n] [-offline -pubkey file] [flags]",
+		"Verifies the signature of the client path with the server, or offline with\nthe signed receipt. Fails if there is no signature.")
+	cf.register(fs)
+	out.register(fs, "verify")
+	fs.StringVar(&challengeUrl, "r", defaultUrl, "server url")
+	fs.BoolVar(&offline, "offline", false, "verify the signed receipt without contacting the server")
+	fs.StringVar(&publicKeyFile, "pubkey", "", "server public key file (offline verification)")
+	fs.StringVar(&tsaCertFile, "tsacert", "", "trusted TSA certificates file (offline verification)")
+	fs.IntVar(&concurrency, "j", 0, "number of files hashed at once (default all the CPUs)")
+	fs.BoolVar(&showProgress, "progress", false, "show the hashing progress")
+	fs.IntVar(&revision, "revision", 0, "revision of the signature to verify (default the one of the client store)")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if code, ok := out.check(fs); !ok {
+		return code
+	}
+	if offline && len(publicKeyFile) == 0 {
+		return out.usage(fs, "missing server public key")
+	}
+
+	res := &result{}
+	c, logFile, err := cf.open(challengeUrl, "")
+	if err != nil {
+		return out.fail(res, codeError, err)
+	}
+	defer logFile.Close()
+	c.SetConcurrency(concurrency)
+	c.SetRevision(revision)
+	if showProgress {
+		c.SetProgress(progressPrinter())
+	}
+	res.Path = c.Path()
+	if !c.Exists() {
+		return out.fail(res, codeNotFound, errNoSignature)
+	}
+	if err = setReceipt(res, c, revision); err != nil {
+		return out.fail(res, codeError, err)
+	}
+
+	if offline {
+		if len(tsaCertFile) > 0 {
+			roots, err := protocol.LoadCertPool(tsaCertFile)
+			if err != nil {
+				return out.fail(res, codeError, err)
+			}
+			c.SetTSARoots(roots)
+		}
+		data, err := os.ReadFile(publicKeyFile)
+		if err != nil {
+			return out.fail(res, codeError, err)
+		}
+		key, err := protocol.ParsePublicKey(data)
+		if err != nil {
+			return out.fail(res, codeError, err)
+		}
+		err = c.VerifyOffline(key)
+	} else {
+		err = c.Restore()
+	}
+	res.FolderHash = c.FolderHash()
+	if err != nil {
+		// the manifest is the one of the revision of the store, compared only
+		// when the folder does not match
+		code := errorCode(err, codeMismatch)
+		if d, err := c.Diff(); err == nil && revision == 0 && code == codeMismatch {
+			res.Diff = d
+			if !out.json() {
+				defer printDiff(d)
+			}
+		}
+		return out.failErr(res, err, codeMismatch)
+	}
+	text := "Same signature"
+	if latest := c.Latest(); latest != nil {
+		res.LatestRevision = latest.RevisionNumber()
+		if res.LatestRevision != res.Revision {
+			text = fmt.Sprintf("Same signature, revision %d superseded by revision %d: %s", res.Revision, res.LatestRevision, latest.Reason)
+		}
+	}
+	res.TimestampToken = c.Timestamp()
+	if res.TimestampToken == client.TimestampUntrusted {
+		text += "\nUntrusted timestamp token: no TSA certificate to check it against, use -tsacert"
+	}
+	return out.ok(res, "verified", text)
+}
+
+func diff(args []string) int {
+	var cf clientFlags
+	var out output
+
+	fs := newFlagSet("diff", "[-p path] [flags]",
+		"Lists the files added (+), removed (-) and modified (M) since the signature,\nwhich must have a manifest.")
+	cf.register(fs)
+	out.register(fs, "diff")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if code, ok := out.check(fs); !ok {
+		return code
+	}
+
+	res := &result{}
+	c, logFile, err := cf.open(defaultUrl, "")
+	if err != nil {
+		return out.fail(res, codeError, err)
+	}
+	defer logFile.Close()
+	res.Path = c.Path()
+	if !c.Exists() {
+		return out.fail(res, codeNotFound, errNoSignature)
+	}
+	d, err := c.Diff()
+	if err != nil {
+		return out.fail(res, codeError, err)
+	}
+	res.Diff = d
+	if d.Empty() {
+		return out.ok(res, "unchanged", "No differences")
+	}
+	if !out.json() {
+		printDiff(d)
+	}
+	return out.fail(res, codeMismatch, errors.New("differences found"))
+}
+
+func list(args []string) int {
+	var out output
+	var configFilePath string
+	var serverUrl string
+	var q protocol.SignatureQuery
+	var from string
+	var to string
+
+	fs := newFlagSet("list", "[-r server url | -c config file] [filters]",
+		"Lists the signatures of the server, or of the server store on the server\nmachine, the oldest first.")
+	out.register(fs, "list")
+	fs.StringVar(&serverUrl, "r", "", "server url (default read the server store of -c)")
+	fs.StringVar(&configFilePath, "c", "config.json", "server config file")
+	fs.StringVar(&q.User, "user", "", "signatures of the user")
+	fs.StringVar(&q.Host, "host", "", "signatures of the host")
+	fs.StringVar(&q.Path, "path", "", "signatures of the paths starting with it")
+	fs.StringVar(&from, "from", "", "signatures signed from this time, RFC 3339 or date")
+	fs.StringVar(&to, "to", "", "signatures signed before this time, RFC 3339 or date")
+	fs.BoolVar(&q.History, "history", false, "list also the superseded revisions")
+	fs.IntVar(&q.Offset, "offset", 0, "signatures to skip")
+	fs.IntVar(&q.Limit, "limit", 0, "maximum number of signatures (default all, 100 from the server)")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if code, ok := out.check(fs); !ok {
+		return code
+	}
+	var err error
+	if q.From, err = parseTime(from); err != nil {
+		return out.usage(fs, err.Error())
+	}
+	if q.To, err = parseTime(to); err != nil {
+		return out.usage(fs, err.Error())
+	}
+	if q.Offset < 0 || q.Limit < 0 {
+		return out.usage(fs, "negative offset or limit")
+	}
+
+	res := &result{}
+	var l *protocol.SignatureList
+	if len(serverUrl) > 0 {
+		if l, err = client.NewClient(serverUrl, "", "", "").Signatures(q); err != nil {
+			return out.failErr(res, err, codeError)
+		}
+	} else {
+		cfg, _ := server.NewLoader().Load(configFilePath)
+		stores, err := server.ListSignatures(cfg)
+		if err != nil {
+			return out.fail(res, codeError, err)
+		}
+		filtered := server.FilterSignatures(stores, q)
+		l = &filtered
+	}
+	if out.json() {
+		res.Data = l
+		return out.ok(res, "ok", "")
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SID\tREV\tSTATUS\tSIGNED\tUSER\tHOST\tPATH")
+	for _, info := range l.Signatures {
+		status := "valid"
+		if info.Revocation != nil {
+			status = "revoked"
+		} else if len(info.SupersededBy) > 0 {
+			status = "superseded"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", info.SID, info.Revision, status, info.Signed.Format(time.RFC3339), info.User, info.HostName, info.Path)
+	}
+	_ = w.Flush()
+	if shown := l.Offset + len(l.Signatures); shown < l.Total {
+		fmt.Printf("%d-%d of %d signatures, more with -offset %d\n", l.Offset+1, shown, l.Total, shown)
+	}
+	return exitOK
+}
+
+// parseTime parses a time of the list filters, RFC 3339 or a date
+func parseTime(s string) (time.Time, error) {
+	if len(s) == 0 {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.New("invalid time " + s)
+}
+
+func show(args []string) int {
+	var cf clientFlags
+	var out output
+	var sid string
+	var serverUrl string
+
+	fs := newFlagSet("show", "[-p path] [flags] | -sid sid [-r server url]",
+		"Shows the signature of the client path and its receipt, without verifying it,\nor the signature of the server with the SID.")
+	cf.register(fs)
+	out.register(fs, "show")
+	fs.StringVar(&sid, "sid", "", "SID of the signature to show from the server")
+	fs.StringVar(&serverUrl, "r", defaultUrl, "server url (with -sid)")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if code, ok := out.check(fs); !ok {
+		return code
+	}
+	if len(sid) > 0 {
+		return showSignature(&out, serverUrl, sid)
+	}
+
+	res := &result{}
+	c, logFile, err := cf.open(defaultUrl, "")
+	if err != nil {
+		return out.fail(res, codeError, err)
+	}
+	defer logFile.Close()
+	res.Path = c.Path()
+	if !c.Exists() {
+		return out.fail(res, codeNotFound, errNoSignature)
+	}
+	store, err := c.Store()
+	if err != nil {
+		return out.fail(res, codeError, err)
+	}
+	if out.json() {
+		_ = setReceipt(res, c, 0)
+		res.Data = store
+		return out.ok(res, "ok", "")
+	}
+	r := store.Receipt
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "path:\t%s\n", store.Path)
+	if len(store.Target) > 0 {
+		fmt.Fprintf(w, "target:\t%s\n", store.Target)
+	}
+	fmt.Fprintf(w, "user:\t%s\n", store.User)
+	fmt.Fprintf(w, "host:\t%s\n", store.HostName)
+	hash := store.Hash
+	if len(hash) == 0 {
+		hash = dirhash.SchemeH1
+	}
+	fmt.Fprintf(w, "hash:\t%s\n", hash)
+	fmt.Fprintf(w, "metadata:\t%t\n", store.Metadata)
+	fmt.Fprintf(w, "sid:\t%s\n", r.SID)
+	fmt.Fprintf(w, "key:\t%s\n", r.Key)
+	fmt.Fprintf(w, "revision:\t%d\n", r.RevisionNumber())
+	if len(r.Supersedes) > 0 {
+		fmt.Fprintf(w, "supersedes:\t%s\n", r.Supersedes)
+		fmt.Fprintf(w, "reason:\t%s\n", r.Reason)
+	}
+	if t, err := r.Time(); err == nil {
+		fmt.Fprintf(w, "signed:\t%s\n", t.Format(time.RFC3339))
+	}
+	if len(r.Algorithm) > 0 {
+		fmt.Fprintf(w, "algorithm:\t%s\n", r.Algorithm)
+	}
+	if len(r.KeyID) > 0 {
+		fmt.Fprintf(w, "signing key id:\t%s\n", r.KeyID)
+	}
+	fmt.Fprintf(w, "timestamp token:\t%t\n", len(r.TimestampToken) > 0)
+	for _, p := range store.Previous {
+		signed := ""
+		if t, err := p.Receipt.Time(); err == nil {
+			signed = t.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "previous revision:\t%d %s %s\n", p.Receipt.RevisionNumber(), p.Receipt.SID, signed)
+	}
+	_ = w.Flush()
+	return exitOK
+}
+
+// showSignature shows the signature of the server with the SID
+func showSignature(out *output, serverUrl string, sid string) int {
+	res := &result{SID: sid}
+	info, err := client.NewClient(serverUrl, "", "", "").Signature(sid)
+	if err != nil {
+		return out.failErr(res, err, codeError)
+	}
+	res.Key = info.Key
+	res.Path = info.Path
+	res.Revision = info.Revision
+	res.Timestamp = &info.Signed
+	if out.json() {
+		res.Data = info
+		return out.ok(res, "ok", "")
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "sid:\t%s\n", info.SID)
+	fmt.Fprintf(w, "key:\t%s\n", info.Key)
+	fmt.Fprintf(w, "path:\t%s\n", info.Path)
+	fmt.Fprintf(w, "user:\t%s\n", info.User)
+	fmt.Fprintf(w, "host:\t%s\n", info.HostName)
+	fmt.Fprintf(w, "signed:\t%s\n", info.Signed.Format(time.RFC3339))
+	fmt.Fprintf(w, "algorithm:\t%s\n", info.Algorithm)
+	fmt.Fprintf(w, "metadata:\t%t\n", info.Metadata)
+	fmt.Fprintf(w, "revision:\t%d\n", info.Revision)
+	if len(info.Supersedes) > 0 {
+		fmt.Fprintf(w, "supersedes:\t%s\n", info.Supersedes)
+		fmt.Fprintf(w, "reason:\t%s\n", info.Reason)
+	}
+	if len(info.SupersededBy) > 0 {
+		fmt.Fprintf(w, "superseded by:\t%s\n", info.SupersededBy)
+	}
+	if len(info.KeyID) > 0 {
+		fmt.Fprintf(w, "signing key id:\t%s\n", info.KeyID)
+	}
+	fmt.Fprintf(w, "timestamp token:\t%t\n", info.Timestamped)
+	if r := info.Revocation; r != nil {
+		fmt.Fprintf(w, "revoked:\t%s by %s\n", r.Time.Format(time.RFC3339), r.By)
+		fmt.Fprintf(w, "revocation reason:\t%s\n", r.Reason)
+		if len(r.Comment) > 0 {
+			fmt.Fprintf(w, "revocation comment:\t%s\n", r.Comment)
+		}
+	}
+	_ = w.Flush()
+	return exitOK
+}
+
+func revoke(args []string) int {
+	var out output
+	var serverUrl string
+	var sid string
+	var reason string
+	var comment string
+
+	fs := newFlagSet("revoke", "-sid sid -reason reason [-comment text] [-r server url]",
+		"Revokes a signature of the server, or a superseded revision: verify then fails\nwith the revocation even when the folder matches.")
+	out.register(fs, "revoke")
+	fs.StringVar(&serverUrl, "r", defaultUrl, "server url")
+	fs.StringVar(&sid, "sid", "", "SID of the signature to revoke")
+	fs.StringVar(&reason, "reason", "", "reason: "+protocol.RevokeUnspecified+", "+protocol.RevokeAcquisitionError+" or "+protocol.RevokeCredentialCompromise)
+	fs.StringVar(&comment, "comment", "", "comment recorded with the revocation")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if code, ok := out.check(fs); !ok {
+		return code
+	}
+	if len(sid) == 0 {
+		return out.usage(fs, "missing SID")
+	}
+	if !protocol.ValidRevokeReason(reason) {
+		return out.usage(fs, "invalid reason "+reason)
+	}
+
+	res := &result{SID: sid}
+	info, err := client.NewClient(serverUrl, "", "", "").Revoke(sid, reason, comment)
+	if err != nil {
+		return out.failErr(res, err, codeError)
+	}
+	res.Key = info.Key
+	res.Path = info.Path
+	res.Revision = info.Revision
+	res.Data = info
+	return out.ok(res, "revoked", "Signature revoked")
+}
+
+func hash(args []string) int {
+	var cf clientFlags
+	var hf hashFlags
+	var out output
+
+	fs := newFlagSet("hash", "[-p path] [flags]",
+		"Prints the hash sign would compute for the client path, nothing is sent\nto the server.")
+	cf.register(fs)
+	hf.register(fs)
+	out.register(fs, "hash")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if code, ok := out.check(fs); !ok {
+		return code
+	}
+
+	res := &result{}
+	c, logFile, err := cf.open(defaultUrl, "")
+	if err != nil {
+		return out.fail(res, codeError, err)
+	}
+	defer logFile.Close()
+	hf.apply(c)
+	res.Path = c.Path()
+	h, err := c.Hash()
+	if err != nil {
+		return out.fail(res, codeError, err)
+	}
+	res.FolderHash = h
+	return out.ok(res, "ok", h)
+}
+
+func pubkey(args []string) int {
+	var out output
+	var challengeUrl string
+
+	fs := newFlagSet("pubkey", "[-r server url]", "Prints the public key the server signs its receipts with.")
+	out.register(fs, "pubkey")
+	fs.StringVar(&challengeUrl, "r", defaultUrl, "server url")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if code, ok := out.check(fs); !ok {
+		return code
+	}
+
+	res := &result{}
+	key, data, err := client.NewClient(challengeUrl, "", "", "").PublicKey()
+	if err != nil {
+		return out.failErr(res, err, codeError)
+	}
+	if out.json() {
+		res.Data = map[string]string{"keyId": protocol.PublicKeyID(key), "pem": string(data)}
+		return out.ok(res, "ok", "")
+	}
+	fmt.Print(string(data))
+	return exitOK
+}
+
+func proof(args []string) int {
+	if len(args) > 0 && args[0] == "verify" {
+		return proofVerify(args[1:])
+	}
+	var cf clientFlags
+	var out output
+	var file string
+	var outFile string
+
+	fs := newFlagSet("proof", "-file file [-p path] [-o proof file] | proof verify [flags]",
+		"Proves a file part of a folder signed with -hash m1, without the other files.")
+	cf.register(fs)
+	out.register(fs, "proof")
+	fs.StringVar(&file, "file", "", "file to prove, in the client path or in the archive")
+	fs.StringVar(&outFile, "o", "", "proof output file (default stdout)")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if code, ok := out.check(fs); !ok {
+		return code
+	}
+	if len(file) == 0 {
+		return out.usage(fs, "missing file")
+	}
+
+	res := &result{}
+	c, logFile, err := cf.open(defaultUrl, "")
+	if err != nil {
+		return out.fail(res, codeError, err)
+	}
+	defer logFile.Close()
+	res.Path = c.Path()
+	if !c.Exists() {
+		return out.fail(res, codeNotFound, errNoSignature)
+	}
+	p, err := c.Prove(file)
+	if err != nil {
+		return out.fail(res, codeMismatch, err)
+	}
+	res.SID = p.Receipt.SID
+	res.Key = p.Receipt.Key
+	res.FolderHash = p.Hash
+	data, _ := json.MarshalIndent(p, "", "\t")
+	if len(outFile) > 0 {
+		if err = os.WriteFile(outFile, data, 0644); err != nil {
+			return out.fail(res, codeError, err)
+		}
+		return out.ok(res, "ok", "")
+	}
+	if out.json() {
+		res.Data = p
+		return out.ok(res, "ok", "")
+	}
+	fmt.Println(string(data))
+	return exitOK
+}
+
+func proofVerify(args []string) int {
+	var out output
+	var proofFile string
+	var publicKeyFile string
+	var file string
+
+	fs := newFlagSet("proof verify", "-proof proof file -pubkey server public key file -file file",
+		"Verifies that the file is the one of the proof and part of the signed folder.")
+	out.register(fs, "proof verify")
+	fs.StringVar(&proofFile, "proof", "", "proof file")
+	fs.StringVar(&publicKeyFile, "pubkey", "", "server public key file")
+	fs.StringVar(&file, "file", "", "file to verify")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if code, ok := out.check(fs); !ok {
+		return code
+	}
+	if len(proofFile) == 0 || len(publicKeyFile) == 0 || len(file) == 0 {
+		return out.usage(fs, "missing proof, public key or file")
+	}
+
+	res := &result{}
+	var p client.FileProof
+	var key ed25519.PublicKey
+	var f *os.File
+	err := func() error {
+		data, err := os.ReadFile(proofFile)
+		if err != nil {
+			return err
+		}
+		if err = json.Unmarshal(data, &p); err != nil {
+			return err
+		}
+		if data, err = os.ReadFile(publicKeyFile); err != nil {
+			return err
+		}
+		if key, err = protocol.ParsePublicKey(data); err != nil {
+			return err
+		}
+		f, err = os.Open(file)
+		return err
+	}()
+	if err != nil {
+		return out.fail(res, codeError, err)
+	}
+	defer f.Close()
+	res.Path = p.Proof.Path
+	res.SID = p.Receipt.SID
+	res.Key = p.Receipt.Key
+	res.FolderHash = p.Hash
+	if err = client.VerifyFileProof(&p, f, key); err != nil {
+		return out.fail(res, codeMismatch, err)
+	}
+	return out.ok(res, "verified", "File included in the signature")
+}
+
+func ledger(args []string) int {
+	if len(args) > 0 && args[0] == "head" {
+		return ledgerHead(args[1:])
+	}
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, "usage: mrsign ledger verify [-c config file] [-head file -pubkey file] | ledger head [-r server url]")
+		return exitUsage
+	}
+	var out output
+	var configFilePath string
+	var headFile string
+	var publicKeyFile string
+
+	fs := newFlagSet("ledger verify", "[-c config file] [-head file -pubkey file]",
+		"Checks the hash chain of the server ledger and that the store matches it,\nand that the ledger reaches a head recorded with ledger head.")
+	out.register(fs, "ledger verify")
+	fs.StringVar(&configFilePath, "c", "config.json", "config file")
+	fs.StringVar(&headFile, "head", "", "signed ledger head recorded with ledger head")
+	fs.StringVar(&publicKeyFile, "pubkey", "", "server public key file, checks the signature of the head")
+	if code, ok := parse(fs, args[1:]); !ok {
+		return code
+	}
+	if code, ok := out.check(fs); !ok {
+		return code
+	}
+	if len(headFile) > 0 && len(publicKeyFile) == 0 {
+		return out.usage(fs, "missing server public key")
+	}
+
+	res := &result{}
+	var anchor *server.LedgerHead
+	if len(headFile) > 0 {
+		head, err := readLedgerHead(headFile, publicKeyFile)
+		if err != nil {
+			return out.fail(res, codeError, err)
+		}
+		anchor = &server.LedgerHead{Seq: head.Seq, Hash: head.Hash}
+	}
+	cfg, _ := server.NewLoader().Load(configFilePath)
+	head, err := server.VerifyLedger(cfg, anchor)
+	if err != nil {
+		return out.fail(res, codeMismatch, err)
+	}
+	res.Data = head
+	return out.ok(res, "verified", fmt.Sprintf("Ledger verified up to entry %d, head %s", head.Seq, head.Hash))
+}
+
+// readLedgerHead reads a signed ledger head and checks its signature with the
+// public key of publicKeyFile
+func readLedgerHead(headFile string, publicKeyFile string) (*protocol.LedgerHead, error) {
+	data, err := os.ReadFile(publicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := protocol.ParsePublicKey(data)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = os.ReadFile(headFile); err != nil {
+		return nil, err
+	}
+	var head protocol.LedgerHead
+	if err = json.Unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+	if err = head.VerifySignature(key); err != nil {
+		return nil, err
+	}
+	return &head, nil
+}
+
+func ledgerHead(args []string) int {
+	var out output
+	var challengeUrl string
+
+	fs := newFlagSet("ledger head", "[-r server url]",
+		"Prints the current head of the server ledger signed by the server, to keep\naway from it for ledger verify -head.")
+	out.register(fs, "ledger head")
+	fs.StringVar(&challengeUrl, "r", defaultUrl, "server url")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if code, ok := out.check(fs); !ok {
+		return code
+	}
+
+	res := &result{}
+	head, err := client.NewClient(challengeUrl, "", "", "").LedgerHead()
+	if err != nil {
+		return out.failErr(res, err, codeError)
+	}
+	if out.json() {
+		res.Data = head
+		return out.ok(res, "ok", "")
+	}
+	data, _ := json.MarshalIndent(head, "", "\t")
+	fmt.Println(string(data))
+	return exitOK
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return exitUsage
+	}
+	switch args[0] {
+	case "-h", "-help", "--help", "help":
+		usage(os.Stdout)
+		return exitOK
+	case "-v", "-version", "--version", "version":
+		fmt.Printf("mrsign %d.%d\n", protocol.MajorVersion, protocol.MinorVersion)
+		return exitOK
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+	usage(os.Stderr)
+	return exitUsage
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
@@ -1,7 +1,7 @@
 /*
- * File: negotiateflags.go
+ * File: main_test.go
  * Project: mrsign
- * Created Date: Tuesday, October 19th 2021, 12:16:54 pm
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
  * Authors: Marcello Russo, Fabio Zito
  * -----
  * Last Modified:
@@ -36,25 +36,27 @@
 
 package main
 
-type FlagsNegotiate uint32
-
-const (
-	negotiateFlagNEGOTIATEUNICODE           FlagsNegotiate = 1 << 0
-	negotiateFlagNEGOTIATEHOSTNAMESUPPLIED                 = 1 << 10
-	negotiateFlagNEGOTIATEUSERNAMESUPPLIED                 = 1 << 11
-	negotiateFlagNEGOTIATFOLDERNAMESUPPLIED                = 1 << 12
-	negotiateFlagNEGOTIATETARGETINFO                       = 1 << 14
-	negotiateFlagNEGOTIATEVERSION                          = 1 << 15
+import (
+	"strings"
+	"testing"
 )
 
-func (field FlagsNegotiate) Has(flags FlagsNegotiate) bool {
-	return field&flags == flags
-}
-
-func (field *FlagsNegotiate) Unset(flags FlagsNegotiate) {
-	*field = *field ^ (*field & flags)
-}
-
-func (field *FlagsNegotiate) Set(flags FlagsNegotiate) {
-	*field |= flags
+func TestReadLine(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"correct horse battery\n", "correct horse battery"},
+		{" padded\t\r\n", " padded\t"},
+		{"no newline", "no newline"},
+		{"first\nsecond\n", "first"},
+	}
+	for _, tt := range tests {
+		got, err := readLine(strings.NewReader(tt.in))
+		if err != nil || got != tt.want {
+			t.Errorf("readLine(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := readLine(strings.NewReader("")); err == nil {
+		t.Error("readLine() of an empty input succeeded")
+	}
 }
@@ -0,0 +1,215 @@
+/*
+ * File: output.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/zitelog/mrsign/client"
+	"github.com/zitelog/mrsign/dirhash"
+)
+
+// Error codes of the JSON results, each one has its exit code
+const (
+	codeMismatch     = "mismatch"
+	codeUsage        = "usage"
+	codeNotFound     = "not_found"
+	codeConflict     = "conflict"
+	codeError        = "error"
+	codeUnauthorized = "unauthorized"
+	codeTransport    = "transport"
+	codeRevoked      = "revoked"
+)
+
+var exitCodes = map[string]int{
+	codeMismatch:     exitMismatch,
+	codeUsage:        exitUsage,
+	codeNotFound:     exitNotFound,
+	codeConflict:     exitConflict,
+	codeError:        exitError,
+	codeUnauthorized: exitUnauthorized,
+	codeTransport:    exitTransport,
+	codeRevoked:      exitRevoked,
+}
+
+// errNoSignature is the error of the commands run on a path without signature
+var errNoSignature = errors.New("no signature found")
+
+// result is the outcome of a command printed with -output json
+type result struct {
+	Command   string `json:"command"`
+	Status    string `json:"status"`
+	ExitCode  int    `json:"exitCode"`
+	ErrorCode string `json:"errorCode,omitempty"`
+	Error     string `json:"error,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+	Path      string `json:"path,omitempty"`
+	SID       string `json:"sid,omitempty"`
+	Key       string `json:"key,omitempty"`
+	Revision  int    `json:"revision,omitempty"`
+	// LatestRevision is the latest revision of the signature, as answered
+	// by the server to verify
+	LatestRevision int        `json:"latestRevision,omitempty"`
+	FolderHash     string     `json:"folderHash,omitempty"`
+	Timestamp      *time.Time `json:"timestamp,omitempty"`
+	// TimestampToken is the trust of the timestamp token checked by the
+	// offline verify, trusted or untrusted
+	TimestampToken string                `json:"timestampToken,omitempty"`
+	Diff           *dirhash.ManifestDiff `json:"diff,omitempty"`
+	// Data is the payload of show, list, proof and pubkey
+	Data any `json:"data,omitempty"`
+}
+
+// output prints the results of a command as text or as JSON
+type output struct {
+	format  string
+	command string
+	// stdout is where the results go, os.Stdout when nil
+	stdout io.Writer
+}
+
+func (o *output) writer() io.Writer {
+	if o.stdout == nil {
+		return os.Stdout
+	}
+	return o.stdout
+}
+
+func (o *output) register(fs *flag.FlagSet, command string) {
+	o.command = command
+	fs.StringVar(&o.format, "output", "text", "output format: text or json")
+}
+
+func (o *output) json() bool {
+	return o.format == "json"
+}
+
+// check fails on an unknown format, once the flags are parsed
+func (o *output) check(fs *flag.FlagSet) (int, bool) {
+	if o.format != "text" && o.format != "json" {
+		return usageError(fs, "unknown output format "+o.format), false
+	}
+	return exitOK, true
+}
+
+// ok prints the result of a successful command, text when not JSON
+func (o *output) ok(res *result, status string, text string) int {
+	res.Command = o.command
+	res.Status = status
+	if o.json() {
+		o.print(res)
+	} else if len(text) > 0 {
+		fmt.Fprintln(o.writer(), text)
+	}
+	return exitOK
+}
+
+// fail prints the error of a command with its code and returns the exit code
+func (o *output) fail(res *result, code string, err error) int {
+	res.Command = o.command
+	res.Status = "failed"
+	res.ErrorCode = code
+	res.ExitCode = exitCodes[code]
+	res.Error = err.Error()
+	if o.json() {
+		o.print(res)
+	} else {
+		fmt.Fprintln(o.writer(), err.Error())
+	}
+	return res.ExitCode
+}
+
+// failErr fails with the code of err, fallback when it has none, and the
+// request ID of the server answer
+func (o *output) failErr(res *result, err error, fallback string) int {
+	var se *client.StatusError
+	if errors.As(err, &se) {
+		res.RequestID = se.RequestID
+	}
+	return o.fail(res, errorCode(err, fallback), err)
+}
+
+func (o *output) print(res *result) {
+	data, _ := json.MarshalIndent(res, "", "\t")
+	fmt.Fprintln(o.writer(), string(data))
+}
+
+// usage fails with a wrong command line, printing the help in text mode
+func (o *output) usage(fs *flag.FlagSet, msg string) int {
+	if o.json() {
+		return o.fail(&result{}, codeUsage, errors.New(msg))
+	}
+	return usageError(fs, msg)
+}
+
+// errorCode returns the code of the server answer or of the transport error,
+// fallback for the others
+func errorCode(err error, fallback string) string {
+	if errors.Is(err, errNoSignature) {
+		return codeNotFound
+	}
+	switch {
+	case errors.Is(err, client.ErrMismatch):
+		return codeMismatch
+	case errors.Is(err, client.ErrNotFound):
+		return codeNotFound
+	case errors.Is(err, client.ErrConflict):
+		return codeConflict
+	case errors.Is(err, client.ErrUnauthorized):
+		return codeUnauthorized
+	case errors.Is(err, client.ErrRevoked):
+		return codeRevoked
+	}
+	var se *client.StatusError
+	if errors.As(err, &se) {
+		return codeError
+	}
+	var ue *url.Error
+	var ne net.Error
+	if errors.As(err, &ue) || errors.As(err, &ne) {
+		return codeTransport
+	}
+	return fallback
+}
@@ -0,0 +1,126 @@
+/*
+ * File: output_test.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/zitelog/mrsign/client"
+)
+
+func TestErrorCode(t *testing.T) {
+	transport := &url.Error{Op: "Post", URL: "http://127.0.0.1:1/v1/api/challenge", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	tests := []struct {
+		name     string
+		err      error
+		fallback string
+		code     string
+		exit     int
+	}{
+		{"no signature", errNoSignature, codeError, codeNotFound, 3},
+		{"mismatch", &client.StatusError{StatusCode: 403, Code: "mismatch"}, codeError, codeMismatch, 1},
+		{"offline mismatch", fmt.Errorf("%w: different signature", client.ErrMismatch), codeError, codeMismatch, 1},
+		{"not found", &client.StatusError{StatusCode: 404, Code: "not_found"}, codeError, codeNotFound, 3},
+		{"conflict", &client.StatusError{StatusCode: 409, Code: "conflict"}, codeError, codeConflict, 4},
+		{"server error", &client.StatusError{StatusCode: 500, Code: "internal"}, codeMismatch, codeError, 5},
+		{"unauthorized", &client.StatusError{StatusCode: 401, Code: "unauthorized"}, codeError, codeUnauthorized, 6},
+		{"plain unauthorized", &client.StatusError{StatusCode: 401}, codeError, codeUnauthorized, 6},
+		{"transport", fmt.Errorf("restore: %w", transport), codeMismatch, codeTransport, 7},
+		{"revoked", &client.StatusError{StatusCode: 410, Code: "revoked"}, codeMismatch, codeRevoked, 8},
+		{"plain revoked", &client.StatusError{StatusCode: 410}, codeMismatch, codeRevoked, 8},
+		{"fallback", errors.New("permission denied"), codeError, codeError, 5},
+		{"mismatch fallback", errors.New("invalid receipt signature"), codeMismatch, codeMismatch, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := errorCode(tt.err, tt.fallback)
+			if code != tt.code || exitCodes[code] != tt.exit {
+				t.Errorf("errorCode() = %s (exit %d), want %s (exit %d)", code, exitCodes[code], tt.code, tt.exit)
+			}
+		})
+	}
+}
+
+func TestOutputJSON(t *testing.T) {
+	signed := time.Date(2026, 10, 17, 4, 46, 16, 0, time.UTC)
+	var b bytes.Buffer
+	out := output{format: "json", command: "verify", stdout: &b}
+	code := out.ok(&result{Path: "/cases/1", SID: "e03f4403-9702-43b9-9289-a23c54d219f1", Revision: 2, LatestRevision: 2, Timestamp: &signed}, "verified", "Same signature")
+	const okJSON = `{
+	"command": "verify",
+	"status": "verified",
+	"exitCode": 0,
+	"path": "/cases/1",
+	"sid": "e03f4403-9702-43b9-9289-a23c54d219f1",
+	"revision": 2,
+	"latestRevision": 2,
+	"timestamp": "2026-10-17T04:46:16Z"
+}
+`
+	if code != exitOK || b.String() != okJSON {
+		t.Errorf("ok() = %d\n%s\nwant\n%s", code, b.String(), okJSON)
+	}
+
+	b.Reset()
+	err := &client.StatusError{StatusCode: 410, Code: "revoked", Message: "revoked by bob", RequestID: "5b4c661939e5e51b43044ee6f1642493"}
+	code = out.failErr(&result{Path: "/cases/1"}, err, codeMismatch)
+	const failJSON = `{
+	"command": "verify",
+	"status": "failed",
+	"exitCode": 8,
+	"errorCode": "revoked",
+	"error": "revoked by bob",
+	"requestId": "5b4c661939e5e51b43044ee6f1642493",
+	"path": "/cases/1"
+}
+`
+	if code != exitRevoked || b.String() != failJSON {
+		t.Errorf("failErr() = %d\n%s\nwant\n%s", code, b.String(), failJSON)
+	}
+
+	b.Reset()
+	out.format = "text"
+	if code = out.fail(&result{}, codeNotFound, errNoSignature); code != exitNotFound || b.String() != "no signature found\n" {
+		t.Errorf("text fail() = %d %q", code, b.String())
+	}
+}
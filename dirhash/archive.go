@@ -0,0 +1,294 @@
+/*
+ * File: archive.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package dirhash
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// FileManifest returns the manifest of a single file, named by its base name
+func (h *Hasher) FileManifest(file string) (*Manifest, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("dirhash: %s is not a regular file", file)
+	}
+	tracker := h.track(1, info.Size())
+	entry, err := h.entry(file, dirFile{name: filepath.Base(file), size: info.Size(), info: info}, &tracker.read)
+	if err == nil {
+		atomic.AddInt64(&tracker.done, 1)
+	}
+	tracker.stop()
+	if err != nil {
+		return nil, err
+	}
+	return &Manifest{Files: []ManifestEntry{entry}, Metadata: h.Metadata}, nil
+}
+
+// ArchiveManifest returns the manifest of the entries of a zip, tar or
+// gzipped tar archive, sorted by path. The symbolic links are recorded as
+// links, the special files follow the Special policy. Patterns do not apply.
+func (h *Hasher) ArchiveManifest(file string) (*Manifest, error) {
+	_, special, err := policies(h.Symlinks, h.Special, h.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	a := &archive{
+		hasher:  h,
+		special: special,
+		entries: make(map[string]ManifestEntry),
+	}
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(512)
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		err = a.readZip(f, info.Size())
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		a.tracker = h.track(UnknownTotal, info.Size())
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(io.TeeReader(br, countingWriter{&a.tracker.read})); err == nil {
+			err = a.readTar(gz)
+		}
+	case len(magic) == 512 && string(magic[257:262]) == "ustar":
+		a.tracker = h.track(UnknownTotal, info.Size())
+		err = a.readTar(io.TeeReader(br, countingWriter{&a.tracker.read}))
+	default:
+		return nil, fmt.Errorf("dirhash: %s is not a zip or tar archive", file)
+	}
+	if a.tracker != nil {
+		a.tracker.stop()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dirhash: %s: %w", file, err)
+	}
+	if h.Metadata {
+		a.dropParents()
+	}
+	m := &Manifest{Metadata: h.Metadata, Skipped: a.skipped}
+	for _, entry := range a.entries {
+		m.Files = append(m.Files, entry)
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	sort.Slice(m.Skipped, func(i, j int) bool { return m.Skipped[i].Path < m.Skipped[j].Path })
+	return m, nil
+}
+
+type archive struct {
+	hasher  *Hasher
+	special string
+	tracker *tracker
+	entries map[string]ManifestEntry
+	skipped []SkippedFile
+}
+
+func (a *archive) readTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		info := hdr.FileInfo()
+		switch hdr.Typeflag {
+		case tar.TypeLink:
+			target, ok := a.entries[hdr.Linkname]
+			if !ok {
+				return fmt.Errorf("hard link %s to a missing entry %s", hdr.Name, hdr.Linkname)
+			}
+			target.Path = hdr.Name
+			target.HardlinkOf = hdr.Linkname
+			err = a.add(target)
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			// the content is counted with the archive bytes
+			err = a.addFile(hdr.Name, info, hdr.Linkname, tr, nil)
+		}
+		if err != nil {
+			return err
+		}
+		atomic.AddInt64(&a.tracker.done, 1)
+	}
+}
+
+func (a *archive) readZip(f *os.File, size int64) error {
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, zf := range zr.File {
+		total += int64(zf.UncompressedSize64)
+	}
+	a.tracker = a.hasher.track(len(zr.File), total)
+	for _, zf := range zr.File {
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		info := zf.FileInfo()
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			// the target of a link is its content
+			target, err := io.ReadAll(io.LimitReader(rc, 4096))
+			if err != nil {
+				_ = rc.Close()
+				return err
+			}
+			link = string(target)
+		}
+		err = a.addFile(zf.Name, info, link, rc, &a.tracker.read)
+		_ = rc.Close()
+		if err != nil {
+			return err
+		}
+		atomic.AddInt64(&a.tracker.done, 1)
+	}
+	return nil
+}
+
+// addFile adds the entry name of the archive, read counts the content bytes
+func (a *archive) addFile(name string, info os.FileInfo, link string, r io.Reader, read *int64) error {
+	mode := info.Mode()
+	name = strings.TrimSuffix(name, "/")
+	entry := ManifestEntry{Path: name, ModTime: info.ModTime().UTC()}
+	if a.hasher.Metadata {
+		entry.Mode = unixMode(mode)
+	}
+	switch {
+	case mode.IsRegular():
+		hf := sha256.New()
+		var w io.Writer = hf
+		if read != nil {
+			w = io.MultiWriter(hf, countingWriter{read})
+		}
+		n, err := io.Copy(w, r)
+		if err != nil {
+			return err
+		}
+		entry.Size = n
+		entry.SHA256 = hex.EncodeToString(hf.Sum(nil))
+		if a.hasher.Metadata {
+			entry.Type = EntryFile
+		}
+	case mode&os.ModeSymlink != 0:
+		if a.hasher.Symlinks == SymlinkSkip {
+			a.skip(name, "symlink", "symlink policy "+SymlinkSkip)
+			return nil
+		}
+		sum := sha256.Sum256([]byte(link))
+		entry.Type = EntrySymlink
+		entry.Link = link
+		entry.SHA256 = hex.EncodeToString(sum[:])
+	case mode.IsDir():
+		if !a.hasher.Metadata {
+			return nil
+		}
+		sum := sha256.Sum256(nil)
+		entry.Type = EntryDir
+		entry.SHA256 = hex.EncodeToString(sum[:])
+	default:
+		if a.special == SpecialSkip {
+			a.skip(name, specialType(mode), "special file policy "+SpecialSkip)
+			return nil
+		}
+		return fmt.Errorf("special file %s (%s)", name, specialType(mode))
+	}
+	return a.add(entry)
+}
+
+// add fails on the paths found twice, the entries the extraction would
+// overwrite are ambiguous
+func (a *archive) add(entry ManifestEntry) error {
+	if _, ok := a.entries[entry.Path]; ok {
+		return fmt.Errorf("duplicate entry %s", entry.Path)
+	}
+	a.entries[entry.Path] = entry
+	return nil
+}
+
+// dropParents leaves out the directories with entries under them, a folder
+// lists only the directories with nothing listed and the archive has to hash
+// as its extraction
+func (a *archive) dropParents() {
+	parents := make(map[string]bool)
+	for name, entry := range a.entries {
+		if entry.Type == EntryDir {
+			continue
+		}
+		for d := path.Dir(name); d != "." && d != "/" && !parents[d]; d = path.Dir(d) {
+			parents[d] = true
+		}
+	}
+	for name, entry := range a.entries {
+		if entry.Type == EntryDir && parents[name] {
+			delete(a.entries, name)
+		}
+	}
+}
+
+func (a *archive) skip(name string, fileType string, reason string) {
+	a.skipped = append(a.skipped, SkippedFile{Path: name, Type: fileType, Reason: reason})
+}
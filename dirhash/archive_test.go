@@ -0,0 +1,242 @@
+/*
+ * File: archive_test.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package dirhash
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type archiveFile struct {
+	name string
+	data string
+	kind byte
+}
+
+func writeTar(t *testing.T, name string, files []archiveFile, compress bool) {
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var tw *tar.Writer
+	if compress {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		tw = tar.NewWriter(gz)
+	} else {
+		tw = tar.NewWriter(f)
+	}
+	defer tw.Close()
+	for _, file := range files {
+		hdr := &tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.data)), Typeflag: file.kind, ModTime: time.Unix(1600000000, 0)}
+		if file.kind != tar.TypeReg {
+			hdr.Size = 0
+			hdr.Linkname = file.data
+		}
+		if err = tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if file.kind == tar.TypeReg {
+			if _, err = tw.Write([]byte(file.data)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func writeZip(t *testing.T, name string, files []archiveFile) {
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	defer zw.Close()
+	for _, file := range files {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write([]byte(file.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestArchiveManifest(t *testing.T) {
+	dir := t.TempDir()
+	files := []archiveFile{
+		{name: "b/c.txt", data: "c", kind: tar.TypeReg},
+		{name: "a.txt", data: "a", kind: tar.TypeReg},
+		{name: "b/d.bin", data: "d", kind: tar.TypeReg},
+	}
+	reversed := []archiveFile{files[2], files[1], files[0]}
+	writeTar(t, filepath.Join(dir, "a.tar"), files, false)
+	writeTar(t, filepath.Join(dir, "a.tar.gz"), reversed, true)
+	writeZip(t, filepath.Join(dir, "a.zip"), files)
+
+	want, err := Hash256([]string{"a.txt", "b/c.txt", "b/d.bin"}, memOpen(map[string]string{"a.txt": "a", "b/c.txt": "c", "b/d.bin": "d"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.tar", "a.tar.gz", "a.zip"} {
+		var last Progress
+		m, err := (&Hasher{Progress: func(p Progress) { last = p }}).ArchiveManifest(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if got, _ := m.SchemeHash(SchemeH1); got != want {
+			t.Errorf("%s: hash %s, want %s", name, got, want)
+		}
+		if last.Files != 3 || last.Bytes == 0 || !last.Done {
+			t.Errorf("%s: progress %+v", name, last)
+		}
+		if total := last.TotalFiles; total != 3 && (name == "a.zip" || total != UnknownTotal) {
+			t.Errorf("%s: total files %d", name, total)
+		}
+	}
+
+	if _, err = (&Hasher{}).ArchiveManifest(filepath.Join(dir, "missing.zip")); err == nil {
+		t.Error("missing archive hashed")
+	}
+	writeFile(t, filepath.Join(dir, "plain.txt"), "not an archive")
+	if _, err = (&Hasher{}).ArchiveManifest(filepath.Join(dir, "plain.txt")); err == nil {
+		t.Error("plain file hashed as an archive")
+	}
+}
+
+func TestArchiveEntries(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.tar")
+	writeTar(t, name, []archiveFile{
+		{name: "a.txt", data: "a", kind: tar.TypeReg},
+		{name: "hard", data: "a.txt", kind: tar.TypeLink},
+		{name: "soft", data: "a.txt", kind: tar.TypeSymlink},
+		{name: "pipe", kind: tar.TypeFifo},
+	}, false)
+	if _, err := (&Hasher{}).ArchiveManifest(name); err == nil {
+		t.Fatal("FIFO entry hashed")
+	}
+	m, err := (&Hasher{Special: SpecialSkip}).ArchiveManifest(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, entry := range m.Files {
+		paths = append(paths, entry.Path)
+	}
+	if want := []string{"a.txt", "hard", "soft"}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("got %v, want %v", paths, want)
+	}
+	if m.Files[1].HardlinkOf != "a.txt" || m.Files[1].SHA256 != m.Files[0].SHA256 {
+		t.Errorf("hard link: %+v", m.Files[1])
+	}
+	if m.Files[2].Type != EntrySymlink || m.Files[2].Link != "a.txt" {
+		t.Errorf("symbolic link: %+v", m.Files[2])
+	}
+	if want := []SkippedFile{{Path: "pipe", Type: "fifo", Reason: "special file policy skip"}}; !reflect.DeepEqual(m.Skipped, want) {
+		t.Errorf("skipped: got %v, want %v", m.Skipped, want)
+	}
+
+	writeTar(t, name, []archiveFile{
+		{name: "a.txt", data: "a", kind: tar.TypeReg},
+		{name: "a.txt", data: "b", kind: tar.TypeReg},
+	}, false)
+	if _, err = (&Hasher{}).ArchiveManifest(name); err == nil {
+		t.Error("duplicate entries hashed")
+	}
+}
+
+func TestArchiveMetadataDirs(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.tar")
+	writeTar(t, name, []archiveFile{
+		{name: "b/", kind: tar.TypeDir},
+		{name: "b/c.txt", data: "c", kind: tar.TypeReg},
+		{name: "e/", kind: tar.TypeDir},
+	}, false)
+	m, err := (&Hasher{Metadata: true}).ArchiveManifest(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the folder the archive extracts to
+	folder := filepath.Join(dir, "x")
+	writeFile(t, filepath.Join(folder, "b", "c.txt"), "c")
+	if err = os.Mkdir(filepath.Join(folder, "e"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	fm, err := (&Hasher{Metadata: true}).Manifest(folder, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := func(m *Manifest) []string {
+		var l []string
+		for _, entry := range m.Files {
+			l = append(l, entry.Path+" "+entry.Type)
+		}
+		return l
+	}
+	if got, want := paths(m), paths(fm); !reflect.DeepEqual(got, want) {
+		t.Errorf("archive entries %v, folder entries %v", got, want)
+	}
+}
+
+func TestFileManifest(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "image.E01")
+	writeFile(t, name, "raw image")
+	m, err := (&Hasher{}).FileManifest(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := m.SchemeHash(SchemeH1)
+	want, _ := Hash256([]string{"image.E01"}, memOpen(map[string]string{"image.E01": "raw image"}))
+	if got != want {
+		t.Errorf("FileManifest hash %s, want %s", got, want)
+	}
+	if _, err = (&Hasher{}).FileManifest(dir); err == nil {
+		t.Error("directory hashed as a file")
+	}
+}
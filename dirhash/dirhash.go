@@ -34,14 +34,13 @@
  * ----------	---	----------------------------------------------------------
  */
 
-package main
+package dirhash
 
 import (
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"sort"
@@ -50,15 +49,35 @@ import (
 
 type Hash func(files []string, open func(string) (io.ReadCloser, error)) (string, error)
 
+// Hash schemes, the scheme prefixes the hashes it computes
+const (
+	SchemeH1 = "h1"
+	SchemeM1 = "m1"
+	SchemeH2 = "h2"
+)
+
+// SchemeHashFunc returns the Hash of scheme, Hash256 when scheme is empty
+func SchemeHashFunc(scheme string) (Hash, error) {
+	switch scheme {
+	case "", SchemeH1:
+		return Hash256, nil
+	case SchemeM1:
+		return HashMerkle, nil
+	case SchemeH2:
+		return Hash2, nil
+	default:
+		return nil, fmt.Errorf("dirhash: unknown hash scheme %q", scheme)
+	}
+}
+
 func Hash256(files []string, open func(string) (io.ReadCloser, error)) (string, error) {
 	h := sha256.New()
 	files = append([]string(nil), files...)
 	sort.Strings(files)
+	if err := checkLines(SchemeH1, files); err != nil {
+		return "", err
+	}
 	for _, file := range files {
-		if strings.Contains(file, "\n") {
-			log.Print("dirhash: filenames with newlines are not supported")
-			continue
-		}
 		r, err := open(file)
 		if err != nil {
 			return "", err
@@ -71,7 +90,7 @@ func Hash256(files []string, open func(string) (io.ReadCloser, error)) (string,
 		}
 		_, _ = fmt.Fprintf(h, "%x  %s\n", hf.Sum(nil), file)
 	}
-	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+	return SchemeH1 + ":" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
 }
 
 func HashDir(dir string, prefix string, exclude []string, hash Hash) (string, error) {
@@ -89,31 +108,16 @@ func HashDir(dir string, prefix string, exclude []string, hash Hash) (string, er
 	return hash(files, osOpen)
 }
 
+// DirFiles lists the files of dir but the ones named in e, following the
+// symbolic links and failing on the special files
 func DirFiles(dir string, prefix string, e map[string]bool) ([]string, error) {
-	var files []string
-	dir = filepath.Clean(dir)
-	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
-		}
-		if _, ok := e[info.Name()]; ok {
-			return nil
-		}
-		rel := file
-		if dir != "." {
-			rel = file[len(dir)+1:]
-		}
-		f := filepath.Join(prefix, rel)
-		files = append(files, filepath.ToSlash(f))
-
-		//fmt.Println("adding ", filepath.ToSlash(f))
-		return nil
-	})
+	files, _, err := dirFiles(dir, prefix, walkOptions{exclude: e})
 	if err != nil {
 		return nil, err
 	}
-	return files, nil
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.name)
+	}
+	return names, nil
 }
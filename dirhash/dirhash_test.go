@@ -0,0 +1,195 @@
+/*
+ * File: dirhash_test.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package dirhash
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func memOpen(files map[string]string) func(string) (io.ReadCloser, error) {
+	return func(name string) (io.ReadCloser, error) {
+		data, ok := files[name]
+		if !ok {
+			return nil, errors.New("file not found: " + name)
+		}
+		return io.NopCloser(bytes.NewReader([]byte(data))), nil
+	}
+}
+
+func TestHash256(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "empty",
+			files: map[string]string{},
+			want:  "h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+		},
+		{
+			name:  "empty file",
+			files: map[string]string{"a.txt": ""},
+			want:  "h1:s/iUeOEIBZq05Vg9VWm/1VTuOXyt+v/RoaQ2Zcs8EyU=",
+		},
+		{
+			name:  "two files",
+			files: map[string]string{"a.txt": "hello\n", "sub/b.txt": "world\n"},
+			want:  "h1:cEiP8rChaw7Gg4phJDRj9Ep9kNhpIidz/E6YKYKlbIc=",
+		},
+		{
+			name:  "binary content",
+			files: map[string]string{"sub/b.txt": "world\n", "a.txt": "hello\n", "z": "\x00\x01"},
+			want:  "h1:fQRt4CLHnxp4/l8no2UAOs30Kx6yWyQoIzrK6V6mfRE=",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var files []string
+			for name := range tt.files {
+				files = append(files, name)
+			}
+			got, err := Hash256(files, memOpen(tt.files))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Hash256() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "hello\n")
+	writeFile(t, filepath.Join(dir, "sub", "b.txt"), "world\n")
+	writeFile(t, filepath.Join(dir, "skip.store"), "excluded")
+
+	got, err := HashDir(dir, "", []string{"skip.store"}, Hash256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "h1:cEiP8rChaw7Gg4phJDRj9Ep9kNhpIidz/E6YKYKlbIc="; got != want {
+		t.Errorf("HashDir() = %s, want %s", got, want)
+	}
+
+	m, err := DirManifest(dir, "", []string{"skip.store"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Hash() != got {
+		t.Errorf("Manifest.Hash() = %s, want %s", m.Hash(), got)
+	}
+}
+
+// TestHashDirBaselineSymlink checks the hash of a folder with a symbolic link
+// out of it against the one of the signatures made before the policies
+func TestHashDirBaselineSymlink(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "in")
+	writeFile(t, filepath.Join(dir, "a.txt"), "hello\n")
+	writeFile(t, filepath.Join(root, "out", "secret.txt"), "outside evidence\n")
+	if err := os.Symlink(filepath.Join("..", "out", "secret.txt"), filepath.Join(dir, "secret")); err != nil {
+		t.Fatal(err)
+	}
+	const want = "h1:DVdpDhm4mbmBzNbvtnrMfYj67A7XjUbxXT6nbRdSd+8="
+
+	got, err := HashDir(dir, "", nil, Hash256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("HashDir() = %s, want %s", got, want)
+	}
+	m, err := (&Hasher{}).Manifest(dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err = m.SchemeHash(SchemeH1); err != nil || got != want {
+		t.Errorf("Manifest.SchemeHash() = %s, %v, want %s", got, err, want)
+	}
+}
+
+func TestManifestDiff(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "hello\n")
+	writeFile(t, filepath.Join(dir, "b.txt"), "world\n")
+	signed, err := DirManifest(dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Join(dir, "a.txt"), "changed\n")
+	writeFile(t, filepath.Join(dir, "c.txt"), "new\n")
+	if err = os.Remove(filepath.Join(dir, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	current, err := DirManifest(dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := signed.Diff(current)
+	if len(d.Added) != 1 || d.Added[0] != "c.txt" {
+		t.Errorf("Added = %v", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0] != "b.txt" {
+		t.Errorf("Removed = %v", d.Removed)
+	}
+	if len(d.Modified) != 1 || d.Modified[0] != "a.txt" {
+		t.Errorf("Modified = %v", d.Modified)
+	}
+	if !signed.Diff(signed).Empty() {
+		t.Error("expected no differences")
+	}
+}
+
+func writeFile(t *testing.T, name string, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
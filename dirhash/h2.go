@@ -0,0 +1,187 @@
+/*
+ * File: h2.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package dirhash
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Hash2 returns the h2: digest of the files. Unlike Hash256 every field is
+// length prefixed, so that any name, newlines and non UTF-8 bytes included,
+// is hashed as the raw bytes the file system returned.
+func Hash2(files []string, open func(string) (io.ReadCloser, error)) (string, error) {
+	h := sha256.New()
+	files = append([]string(nil), files...)
+	sort.Strings(files)
+	for _, file := range files {
+		r, err := open(file)
+		if err != nil {
+			return "", err
+		}
+		hf := sha256.New()
+		_, err = io.Copy(hf, r)
+		_ = r.Close()
+		if err != nil {
+			return "", err
+		}
+		writeRecord(h, "", hf.Sum(nil), file)
+	}
+	return SchemeH2 + ":" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// Hash2 returns the same h2: digest Hash2 computes over the manifest files
+func (m *Manifest) Hash2() (string, error) {
+	h := sha256.New()
+	for _, entry := range m.Files {
+		digest, err := hex.DecodeString(entry.SHA256)
+		if err != nil {
+			return "", err
+		}
+		writeRecord(h, m.meta(entry), digest, entry.Path)
+	}
+	return SchemeH2 + ":" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeRecord hashes the record of a file: the metadata, the digest and the
+// path, each preceded by its length
+func writeRecord(h hash.Hash, meta string, digest []byte, path string) {
+	for _, field := range []string{meta, string(digest), path} {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(field)))
+		h.Write(n[:])
+		_, _ = io.WriteString(h, field)
+	}
+}
+
+// checkLines fails on the names the line based schemes (h1 and m1) cannot
+// tell apart from the next entry
+func checkLines(scheme string, files []string) error {
+	for _, file := range files {
+		if strings.Contains(file, "\n") {
+			return fmt.Errorf("dirhash: %s cannot represent the filename %q, use %s", scheme, file, SchemeH2)
+		}
+	}
+	return nil
+}
+
+// manifestEntryJSON is ManifestEntry as saved, a path that is not valid UTF-8
+// is saved also as RawPath, JSON strings would lose its bytes, and so are the
+// link targets
+type manifestEntryJSON struct {
+	Path          string `json:"path"`
+	RawPath       []byte `json:"rawPath,omitempty"`
+	Link          string `json:"link,omitempty"`
+	RawLink       []byte `json:"rawLink,omitempty"`
+	HardlinkOf    string `json:"hardlinkOf,omitempty"`
+	RawHardlinkOf []byte `json:"rawHardlinkOf,omitempty"`
+	manifestEntryFields
+}
+
+type manifestEntryFields ManifestEntry
+
+// rawString returns s as a valid JSON string and, when it is not valid UTF-8,
+// its bytes
+func rawString(s string) (string, []byte) {
+	if utf8.ValidString(s) {
+		return s, nil
+	}
+	return strings.ToValidUTF8(s, string(utf8.RuneError)), []byte(s)
+}
+
+// fromRaw returns the bytes of raw when saved, s otherwise
+func fromRaw(s string, raw []byte) string {
+	if raw != nil {
+		return string(raw)
+	}
+	return s
+}
+
+func (e ManifestEntry) MarshalJSON() ([]byte, error) {
+	out := manifestEntryJSON{manifestEntryFields: manifestEntryFields(e)}
+	out.Path, out.RawPath = rawString(e.Path)
+	out.Link, out.RawLink = rawString(e.Link)
+	out.HardlinkOf, out.RawHardlinkOf = rawString(e.HardlinkOf)
+	return json.Marshal(out)
+}
+
+func (e *ManifestEntry) UnmarshalJSON(data []byte) error {
+	var in manifestEntryJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*e = ManifestEntry(in.manifestEntryFields)
+	e.Path = fromRaw(in.Path, in.RawPath)
+	e.Link = fromRaw(in.Link, in.RawLink)
+	e.HardlinkOf = fromRaw(in.HardlinkOf, in.RawHardlinkOf)
+	return nil
+}
+
+// skippedFileJSON is SkippedFile as saved, with the raw path as
+// manifestEntryJSON
+type skippedFileJSON struct {
+	Path    string `json:"path"`
+	RawPath []byte `json:"rawPath,omitempty"`
+	skippedFileFields
+}
+
+type skippedFileFields SkippedFile
+
+func (f SkippedFile) MarshalJSON() ([]byte, error) {
+	out := skippedFileJSON{skippedFileFields: skippedFileFields(f)}
+	out.Path, out.RawPath = rawString(f.Path)
+	return json.Marshal(out)
+}
+
+func (f *SkippedFile) UnmarshalJSON(data []byte) error {
+	var in skippedFileJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*f = SkippedFile(in.skippedFileFields)
+	f.Path = fromRaw(in.Path, in.RawPath)
+	return nil
+}
@@ -0,0 +1,155 @@
+/*
+ * File: h2_test.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package dirhash
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHash2(t *testing.T) {
+	// With h1 a file named "a\n<digest>  b" would forge the line of b
+	tests := []map[string]string{
+		{"a": "x", "b": "y"},
+		{"a\nb": "x"},
+		{"a\xff": "x"},
+		{"a\xfe": "x"},
+		{"a": "x", "b\x00": "y"},
+	}
+	seen := make(map[string]int)
+	for i, files := range tests {
+		var names []string
+		for name := range files {
+			names = append(names, name)
+		}
+		got, err := Hash2(names, memOpen(files))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(got, SchemeH2+":") {
+			t.Errorf("Hash2() = %s, want the %s: prefix", got, SchemeH2)
+		}
+		if j, ok := seen[got]; ok {
+			t.Errorf("files %d and %d have the same hash %s", j, i, got)
+		}
+		seen[got] = i
+	}
+
+	_, err := Hash256([]string{"a\nb"}, memOpen(tests[1]))
+	if err == nil || !strings.Contains(err.Error(), SchemeH2) {
+		t.Errorf("Hash256() error = %v, want a pointer to %s", err, SchemeH2)
+	}
+	if _, err = HashMerkle([]string{"a\nb"}, memOpen(tests[1])); err == nil {
+		t.Error("HashMerkle() hashed a filename with a newline")
+	}
+}
+
+func TestHash2Dir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "a")
+	for _, name := range []string{"new\nline", "raw\xff"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Skip("file system rejects", name)
+		}
+	}
+
+	m, err := DirManifest(dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 3 {
+		t.Fatalf("got %d files, want 3", len(m.Files))
+	}
+	for _, scheme := range []string{SchemeH1, SchemeM1} {
+		if _, err = m.SchemeHash(scheme); err == nil {
+			t.Errorf("%s hashed a filename with a newline", scheme)
+		}
+	}
+	got, err := m.SchemeHash(SchemeH2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := HashDir(dir, "", nil, Hash2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("Manifest.Hash2() = %s, want %s", got, want)
+	}
+
+	// The raw bytes survive the manifest file
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Manifest
+	if err = json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := saved.SchemeHash(SchemeH2); again != got {
+		t.Errorf("saved manifest hash %s, want %s", again, got)
+	}
+	if d := m.Diff(&saved); !d.Empty() {
+		t.Errorf("saved manifest differs: %+v", d)
+	}
+}
+
+func TestManifestRawJSON(t *testing.T) {
+	m := &Manifest{
+		Metadata: true,
+		Files: []ManifestEntry{
+			{Path: "link\xfe", Type: EntrySymlink, Link: "target\xff", SHA256: "00"},
+			{Path: "b\xfd", Type: EntryFile, HardlinkOf: "a\xfc", SHA256: "00"},
+		},
+		Skipped: []SkippedFile{{Path: "fifo\xfb", Type: "fifo", Reason: "special file policy skip"}},
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Manifest
+	if err = json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&saved, m) {
+		t.Errorf("saved manifest = %+v, want %+v", saved, *m)
+	}
+}
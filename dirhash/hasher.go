@@ -0,0 +1,369 @@
+/*
+ * File: hasher.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package dirhash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultProgressInterval = 500 * time.Millisecond
+
+// UnknownTotal is the TotalFiles of the tar archives, whose entries are
+// counted while they are read
+const UnknownTotal = -1
+
+// Progress of a Hasher, Bytes grows while the files are read
+type Progress struct {
+	Files      int
+	TotalFiles int
+	Bytes      int64
+	TotalBytes int64
+	Elapsed    time.Duration
+	// Done is set on the last report
+	Done bool
+}
+
+// ETA estimates the time left from the bytes read so far
+func (p Progress) ETA() time.Duration {
+	if p.Bytes == 0 || p.Bytes >= p.TotalBytes {
+		return 0
+	}
+	return time.Duration(float64(p.Elapsed) * float64(p.TotalBytes-p.Bytes) / float64(p.Bytes))
+}
+
+// Hasher hashes the files of a folder with a pool of workers, the results do
+// not depend on the number of workers
+type Hasher struct {
+	// Concurrency is the number of files read at once, runtime.NumCPU() when 0
+	Concurrency int
+	// Progress, when set, is called every ProgressInterval and at the end,
+	// always from the same goroutine
+	Progress         func(Progress)
+	ProgressInterval time.Duration
+	// Patterns, when set, select the files to hash
+	Patterns *Patterns
+	// Metadata adds the type, mode bits, size and modification time of the
+	// files, the symbolic link targets and the empty directories
+	Metadata bool
+	// Symlinks is the symbolic link policy, SymlinkFollow when empty, or
+	// SymlinkLink with Metadata
+	Symlinks string
+	// Special is the special file policy, SpecialFail when empty
+	Special string
+}
+
+// HashDir returns the hash of scheme of the files of dir
+func (h *Hasher) HashDir(dir string, prefix string, exclude []string, scheme string) (string, error) {
+	if _, err := SchemeHashFunc(scheme); err != nil {
+		return "", err
+	}
+	m, err := h.Manifest(dir, prefix, exclude)
+	if err != nil {
+		return "", err
+	}
+	return m.SchemeHash(scheme)
+}
+
+// Manifest returns the manifest of the files of dir, sorted by path
+func (h *Hasher) Manifest(dir string, prefix string, exclude []string) (*Manifest, error) {
+	e := make(map[string]bool)
+	for _, l := range exclude {
+		e[l] = true
+	}
+	files, skipped, err := dirFiles(dir, prefix, walkOptions{
+		exclude:  e,
+		patterns: h.Patterns,
+		metadata: h.Metadata,
+		symlinks: h.Symlinks,
+		special:  h.Special,
+	})
+	if err != nil {
+		return nil, err
+	}
+	all := files
+	sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+
+	// The hard links are read once, from the first of their paths
+	var kept []dirFile
+	var total int64
+	first := make(map[fileID]int)
+	hardlinks := make(map[int]int)
+	for i, f := range all {
+		if f.linked {
+			if k, ok := first[f.id]; ok {
+				hardlinks[i] = k
+				continue
+			}
+			first[f.id] = i
+		}
+		kept = append(kept, f)
+		if f.info.Mode().IsRegular() {
+			total += f.size
+		}
+	}
+
+	workers := h.Concurrency
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(kept) {
+		workers = len(kept)
+	}
+
+	tracker := h.track(len(kept), total)
+	read := &tracker.read
+
+	hashed := make([]ManifestEntry, len(kept))
+	jobs := make(chan int)
+	var firstErr error
+	var errOnce sync.Once
+	failed := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				f := kept[i]
+				entry, err := h.entry(filepath.Join(dir, strings.TrimPrefix(f.name, prefix)), f, read)
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						close(failed)
+					})
+					continue
+				}
+				hashed[i] = entry
+				atomic.AddInt64(&tracker.done, 1)
+			}
+		}()
+	}
+feed:
+	for i := range kept {
+		select {
+		case jobs <- i:
+		case <-failed:
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	tracker.stop()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	entries := make([]ManifestEntry, 0, len(all))
+	byPath := make(map[string]ManifestEntry, len(kept))
+	for _, entry := range hashed {
+		byPath[entry.Path] = entry
+	}
+	for i, f := range all {
+		entry, ok := byPath[f.name]
+		if k, linked := hardlinks[i]; linked {
+			entry = byPath[all[k].name]
+			entry.Path = f.name
+			entry.HardlinkOf = all[k].name
+			ok = true
+		}
+		if ok {
+			entries = append(entries, entry)
+		}
+	}
+	return &Manifest{Files: entries, Metadata: h.Metadata, Skipped: skipped}, nil
+}
+
+func (h *Hasher) entry(name string, f dirFile, read *int64) (ManifestEntry, error) {
+	mode := f.info.Mode()
+	if mode&os.ModeSymlink != 0 {
+		// Recorded as a link, the target path is hashed
+		sum := sha256.Sum256([]byte(f.link))
+		entry := ManifestEntry{
+			Path:    f.name,
+			Type:    EntrySymlink,
+			ModTime: f.info.ModTime().UTC(),
+			Link:    f.link,
+			SHA256:  hex.EncodeToString(sum[:]),
+		}
+		if h.Metadata {
+			entry.Mode = unixMode(mode)
+		}
+		return entry, nil
+	}
+	if !h.Metadata {
+		entry, err := fileEntry(name, f.name, read)
+		entry.Link = f.link
+		return entry, err
+	}
+	switch {
+	case mode.IsRegular():
+		entry, err := fileEntry(name, f.name, read)
+		if err != nil {
+			return entry, err
+		}
+		entry.Type = EntryFile
+		entry.Mode = unixMode(mode)
+		entry.Link = f.link
+		return entry, nil
+	case mode.IsDir():
+		sum := sha256.Sum256(nil)
+		return ManifestEntry{
+			Path:    f.name,
+			Type:    EntryDir,
+			Mode:    unixMode(mode),
+			ModTime: f.info.ModTime().UTC(),
+			SHA256:  hex.EncodeToString(sum[:]),
+		}, nil
+	default:
+		return ManifestEntry{}, fmt.Errorf("dirhash: unsupported file type %s: %s", mode.Type(), f.name)
+	}
+}
+
+// tracker counts the files and bytes hashed for the Progress callback
+type tracker struct {
+	done       int64
+	read       int64
+	totalFiles int
+	totalBytes int64
+	start      time.Time
+	stopped    chan struct{}
+	reporter   sync.WaitGroup
+}
+
+// track starts reporting the progress of the hash of files and bytes, stop
+// makes the last report
+func (h *Hasher) track(files int, bytes int64) *tracker {
+	t := &tracker{totalFiles: files, totalBytes: bytes, start: time.Now(), stopped: make(chan struct{})}
+	if h.Progress == nil {
+		return t
+	}
+	interval := h.ProgressInterval
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+	t.reporter.Add(1)
+	go func() {
+		defer t.reporter.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				h.Progress(t.progress())
+			case <-t.stopped:
+				p := t.progress()
+				p.Done = true
+				h.Progress(p)
+				return
+			}
+		}
+	}()
+	return t
+}
+
+func (t *tracker) progress() Progress {
+	return Progress{
+		Files:      int(atomic.LoadInt64(&t.done)),
+		TotalFiles: t.totalFiles,
+		Bytes:      atomic.LoadInt64(&t.read),
+		TotalBytes: t.totalBytes,
+		Elapsed:    time.Since(t.start),
+	}
+}
+
+func (t *tracker) stop() {
+	close(t.stopped)
+	t.reporter.Wait()
+}
+
+// unixMode returns the permission bits of mode as in chmod
+func unixMode(mode os.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		m |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= 01000
+	}
+	return m
+}
+
+// countingWriter adds the bytes written to n
+type countingWriter struct {
+	n *int64
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	atomic.AddInt64(w.n, int64(len(p)))
+	return len(p), nil
+}
+
+func fileEntry(name string, file string, read *int64) (ManifestEntry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	hf := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(hf, countingWriter{read}), f); err != nil {
+		return ManifestEntry{}, err
+	}
+	return ManifestEntry{
+		Path:    file,
+		Size:    info.Size(),
+		ModTime: info.ModTime().UTC(),
+		SHA256:  hex.EncodeToString(hf.Sum(nil)),
+	}, nil
+}
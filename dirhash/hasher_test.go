@@ -0,0 +1,198 @@
+/*
+ * File: hasher_test.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package dirhash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHasherDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	var total int64
+	for i := 0; i < 40; i++ {
+		data := strings.Repeat(fmt.Sprint(i), i*100)
+		writeFile(t, filepath.Join(dir, fmt.Sprintf("d%d", i%3), fmt.Sprintf("f%02d.bin", i)), data)
+		total += int64(len(data))
+	}
+	writeFile(t, filepath.Join(dir, "a.txt"), "a")
+	writeFile(t, filepath.Join(dir, "a", "b.txt"), "b")
+	total += 2
+
+	for _, scheme := range []string{SchemeH1, SchemeM1, SchemeH2} {
+		hash, _ := SchemeHashFunc(scheme)
+		want, err := HashDir(dir, "", nil, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, workers := range []int{1, 2, 8, 64} {
+			var mutex sync.Mutex
+			var last Progress
+			h := &Hasher{
+				Concurrency:      workers,
+				ProgressInterval: time.Millisecond,
+				Progress: func(p Progress) {
+					mutex.Lock()
+					last = p
+					mutex.Unlock()
+				},
+			}
+			got, err := h.HashDir(dir, "", nil, scheme)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("%s with %d workers = %s, want %s", scheme, workers, got, want)
+			}
+			if last.Files != 42 || last.TotalFiles != 42 || last.Bytes != total || last.TotalBytes != total {
+				t.Errorf("%s with %d workers: last progress %+v", scheme, workers, last)
+			}
+		}
+	}
+}
+
+func TestProgressETA(t *testing.T) {
+	p := Progress{Bytes: 25, TotalBytes: 100, Elapsed: time.Second}
+	if eta := p.ETA(); eta != 3*time.Second {
+		t.Errorf("ETA() = %v, want 3s", eta)
+	}
+	p.Bytes = 100
+	if eta := p.ETA(); eta != 0 {
+		t.Errorf("ETA() when done = %v", eta)
+	}
+}
+
+func TestHasherMetadata(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "hello\n")
+	writeFile(t, filepath.Join(dir, "sub", "b.txt"), "world\n")
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("a.txt", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2021, 10, 19, 12, 16, 54, 0, time.UTC)
+	for _, name := range []string{"a.txt", "sub/b.txt", "empty"} {
+		if err := os.Chtimes(filepath.Join(dir, name), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := &Hasher{Metadata: true}
+	signed, err := h.Manifest(dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, e := range signed.Files {
+		types = append(types, e.Path+":"+e.Type)
+	}
+	if got := strings.Join(types, " "); got != "a.txt:f empty:d link:l sub/b.txt:f" {
+		t.Fatalf("entries = %s", got)
+	}
+	plain, err := (&Hasher{}).HashDir(dir, "", nil, SchemeH1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed.Hash() == plain {
+		t.Error("metadata hash equals the content hash")
+	}
+
+	tree, err := signed.MerkleTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := tree.Proof("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.VerifyFile(strings.NewReader("hello\n"), tree.Hash()); err != nil {
+		t.Errorf("metadata proof: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func() error
+		want   ManifestDiff
+	}{
+		{"chmod", func() error { return os.Chmod(filepath.Join(dir, "a.txt"), 0600) },
+			ManifestDiff{Modified: []string{"a.txt"}}},
+		{"mtime", func() error {
+			return os.Chtimes(filepath.Join(dir, "sub", "b.txt"), mtime, mtime.Add(time.Second))
+		}, ManifestDiff{Modified: []string{"sub/b.txt"}}},
+		{"symlink target", func() error {
+			_ = os.Remove(filepath.Join(dir, "link"))
+			return os.Symlink("sub/b.txt", filepath.Join(dir, "link"))
+		}, ManifestDiff{Modified: []string{"link"}}},
+		{"empty directory", func() error { return os.Remove(filepath.Join(dir, "empty")) },
+			ManifestDiff{Removed: []string{"empty"}}},
+	}
+	for _, tt := range tests {
+		if err = tt.change(); err != nil {
+			t.Fatal(err)
+		}
+		current, err := h.Manifest(dir, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current.Hash() == signed.Hash() {
+			t.Errorf("%s: hash unchanged", tt.name)
+		}
+		d := signed.Diff(current)
+		if !reflect.DeepEqual(d.Modified, tt.want.Modified) || !reflect.DeepEqual(d.Removed, tt.want.Removed) {
+			t.Errorf("%s: diff %+v, want %+v", tt.name, d, tt.want)
+		}
+		signed = current
+
+		if tt.name == "chmod" || tt.name == "mtime" {
+			content, err := (&Hasher{}).HashDir(dir, "", nil, SchemeH1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if content != plain {
+				t.Errorf("%s: the content hash depends on the metadata", tt.name)
+			}
+		}
+	}
+}
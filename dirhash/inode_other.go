@@ -1,7 +1,9 @@
+//go:build !unix
+
 /*
- * File: serverStore.go
+ * File: inode_other.go
  * Project: mrsign
- * Created Date: Tuesday, October 19th 2021, 12:16:54 pm
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
  * Authors: Marcello Russo, Fabio Zito
  * -----
  * Last Modified:
@@ -34,16 +36,20 @@
  * ----------	---	----------------------------------------------------------
  */
 
-package main
+package dirhash
+
+import (
+	"os"
+	"path/filepath"
+)
 
-const ServerStoreFile = "zserver.store"
+// inode reports no hard links where the inodes are not known
+func inode(info os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
 
-type ServerStore struct {
-	Key             string `json:"key"`
-	User            string `json:"user"`
-	HostName        string `json:"hostName"`
-	Path            string `json:"path"`
-	Timestamp       string `json:"timestamp"`
-	ServerChallenge string `json:"serverChallenge"`
-	Result          []byte `json:"result"`
+// dirID identifies the directory at abs by its real path where the inodes are
+// not known
+func dirID(abs string, info os.FileInfo) (string, error) {
+	return filepath.EvalSymlinks(abs)
 }
@@ -0,0 +1,185 @@
+/*
+ * File: manifest.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package dirhash
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Entry types of a metadata manifest
+const (
+	EntryFile    = "f"
+	EntrySymlink = "l"
+	EntryDir     = "d"
+)
+
+// ManifestEntry is a file of the manifest, with Metadata also a symbolic link
+// (SHA256 is the one of the target) or an empty directory
+type ManifestEntry struct {
+	Path    string    `json:"path"`
+	Type    string    `json:"type,omitempty"`
+	Mode    uint32    `json:"mode,omitempty"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Link    string    `json:"link,omitempty"`
+	SHA256  string    `json:"sha256"`
+	// HardlinkOf is the path hashed for the hard link
+	HardlinkOf string `json:"hardlinkOf,omitempty"`
+}
+
+type Manifest struct {
+	Metadata bool            `json:"metadata,omitempty"`
+	Files    []ManifestEntry `json:"files"`
+	// Skipped are the files left out by the symlink and special file policies
+	Skipped []SkippedFile `json:"skipped,omitempty"`
+}
+
+// meta returns the metadata hashed with the entry, empty without Metadata
+func (m *Manifest) meta(entry ManifestEntry) string {
+	if !m.Metadata {
+		return ""
+	}
+	return fmt.Sprintf("%s %04o %d %d", entry.Type, entry.Mode, entry.Size, entry.ModTime.UnixNano())
+}
+
+type ManifestDiff struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+func DirManifest(dir string, prefix string, exclude []string) (*Manifest, error) {
+	return (&Hasher{Concurrency: 1}).Manifest(dir, prefix, exclude)
+}
+
+// Hash returns the same h1: digest Hash256 computes over the manifest files
+func (m *Manifest) Hash() string {
+	h := sha256.New()
+	for _, entry := range m.Files {
+		_, _ = io.WriteString(h, entryLine(m.meta(entry), entry.SHA256, entry.Path))
+	}
+	return SchemeH1 + ":" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// MerkleTree returns the Merkle tree of the manifest files
+func (m *Manifest) MerkleTree() (*MerkleTree, error) {
+	if err := checkLines(SchemeM1, m.paths()); err != nil {
+		return nil, err
+	}
+	leaves := make([]merkleEntry, 0, len(m.Files))
+	for _, entry := range m.Files {
+		digest, err := hex.DecodeString(entry.SHA256)
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, merkleEntry{path: entry.Path, digest: digest, meta: m.meta(entry)})
+	}
+	return newMerkleTree(leaves), nil
+}
+
+// SchemeHash returns the digest the hash of scheme computes over the
+// manifest files
+func (m *Manifest) SchemeHash(scheme string) (string, error) {
+	switch scheme {
+	case "", SchemeH1:
+		if err := checkLines(SchemeH1, m.paths()); err != nil {
+			return "", err
+		}
+		return m.Hash(), nil
+	case SchemeM1:
+		t, err := m.MerkleTree()
+		if err != nil {
+			return "", err
+		}
+		return t.Hash(), nil
+	case SchemeH2:
+		return m.Hash2()
+	default:
+		return "", fmt.Errorf("dirhash: unknown hash scheme %q", scheme)
+	}
+}
+
+func (m *Manifest) paths() []string {
+	paths := make([]string, 0, len(m.Files))
+	for _, entry := range m.Files {
+		paths = append(paths, entry.Path)
+	}
+	return paths
+}
+
+// entryLine is the line hashed for a file, the "sha256  path" line of
+// Hash256 preceded by the metadata, if any
+func entryLine(meta string, digest string, path string) string {
+	if len(meta) > 0 {
+		return meta + " " + digest + "  " + path + "\n"
+	}
+	return digest + "  " + path + "\n"
+}
+
+func (m *Manifest) Diff(current *Manifest) *ManifestDiff {
+	d := &ManifestDiff{}
+	signed := make(map[string]ManifestEntry)
+	for _, entry := range m.Files {
+		signed[entry.Path] = entry
+	}
+	for _, entry := range current.Files {
+		old, ok := signed[entry.Path]
+		if !ok {
+			d.Added = append(d.Added, entry.Path)
+			continue
+		}
+		delete(signed, entry.Path)
+		if old.Size != entry.Size || old.SHA256 != entry.SHA256 || m.meta(old) != m.meta(entry) {
+			d.Modified = append(d.Modified, entry.Path)
+		}
+	}
+	for path := range signed {
+		d.Removed = append(d.Removed, path)
+	}
+	sort.Strings(d.Removed)
+	return d
+}
+
+func (d *ManifestDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
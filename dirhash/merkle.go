@@ -0,0 +1,253 @@
+/*
+ * File: merkle.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package dirhash
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"sort"
+)
+
+// MerkleTree is a RFC 6962 Merkle tree over the files sorted by path, the
+// leaves hash the same lines of Manifest.Hash
+type MerkleTree struct {
+	entries []merkleEntry
+	leaves  [][]byte
+}
+
+type merkleEntry struct {
+	path   string
+	digest []byte
+	meta   string
+}
+
+// MerkleProof is the inclusion proof of a file in a MerkleTree
+type MerkleProof struct {
+	Path   string   `json:"path"`
+	Meta   string   `json:"meta,omitempty"`
+	SHA256 string   `json:"sha256"`
+	Index  int      `json:"index"`
+	Size   int      `json:"size"`
+	Hashes []string `json:"hashes"`
+}
+
+// HashMerkle returns the m1: root of the Merkle tree of the files
+func HashMerkle(files []string, open func(string) (io.ReadCloser, error)) (string, error) {
+	if err := checkLines(SchemeM1, files); err != nil {
+		return "", err
+	}
+	digests := make(map[string][]byte)
+	for _, file := range files {
+		r, err := open(file)
+		if err != nil {
+			return "", err
+		}
+		hf := sha256.New()
+		_, err = io.Copy(hf, r)
+		_ = r.Close()
+		if err != nil {
+			return "", err
+		}
+		digests[file] = hf.Sum(nil)
+	}
+	return NewMerkleTree(digests).Hash(), nil
+}
+
+// NewMerkleTree builds the tree of the SHA-256 digests by path
+func NewMerkleTree(digests map[string][]byte) *MerkleTree {
+	entries := make([]merkleEntry, 0, len(digests))
+	for path, digest := range digests {
+		entries = append(entries, merkleEntry{path: path, digest: digest})
+	}
+	return newMerkleTree(entries)
+}
+
+func newMerkleTree(entries []merkleEntry) *MerkleTree {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+	t := &MerkleTree{entries: entries}
+	for _, e := range entries {
+		t.leaves = append(t.leaves, merkleLeaf(e.meta, e.path, e.digest))
+	}
+	return t
+}
+
+// DirMerkleTree builds the tree of the files of dir
+func DirMerkleTree(dir string, prefix string, exclude []string) (*MerkleTree, error) {
+	m, err := DirManifest(dir, prefix, exclude)
+	if err != nil {
+		return nil, err
+	}
+	return m.MerkleTree()
+}
+
+func (t *MerkleTree) Root() []byte {
+	return merkleRoot(t.leaves)
+}
+
+func (t *MerkleTree) Hash() string {
+	return SchemeM1 + ":" + base64.StdEncoding.EncodeToString(t.Root())
+}
+
+// Proof returns the inclusion proof of the file at path
+func (t *MerkleTree) Proof(path string) (*MerkleProof, error) {
+	index := sort.Search(len(t.entries), func(i int) bool { return t.entries[i].path >= path })
+	if index == len(t.entries) || t.entries[index].path != path {
+		return nil, fmt.Errorf("dirhash: %s not in the tree", path)
+	}
+	p := &MerkleProof{
+		Path:   path,
+		SHA256: hex.EncodeToString(t.entries[index].digest),
+		Meta:   t.entries[index].meta,
+		Index:  index,
+		Size:   len(t.leaves),
+	}
+	for _, h := range merklePath(index, t.leaves) {
+		p.Hashes = append(p.Hashes, hex.EncodeToString(h))
+	}
+	return p, nil
+}
+
+// Verify checks that the proof leads to the m1: hash
+func (p *MerkleProof) Verify(hash string) error {
+	root, err := p.root()
+	if err != nil {
+		return err
+	}
+	if SchemeM1+":"+base64.StdEncoding.EncodeToString(root) != hash {
+		return errors.New("dirhash: inclusion proof does not match the hash")
+	}
+	return nil
+}
+
+// VerifyFile checks that r is the content of the file of the proof and that
+// the proof leads to the m1: hash
+func (p *MerkleProof) VerifyFile(r io.Reader, hash string) error {
+	hf := sha256.New()
+	if _, err := io.Copy(hf, r); err != nil {
+		return err
+	}
+	if hex.EncodeToString(hf.Sum(nil)) != p.SHA256 {
+		return fmt.Errorf("dirhash: %s has been modified", p.Path)
+	}
+	return p.Verify(hash)
+}
+
+// root recomputes the tree root from the leaf and its audit path, as in
+// RFC 9162 section 2.1.3.2
+func (p *MerkleProof) root() ([]byte, error) {
+	if p.Index < 0 || p.Index >= p.Size {
+		return nil, errors.New("dirhash: invalid inclusion proof index")
+	}
+	digest, err := hex.DecodeString(p.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	r := merkleLeaf(p.Meta, p.Path, digest)
+	fn, sn := p.Index, p.Size-1
+	for _, s := range p.Hashes {
+		h, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		if sn == 0 {
+			return nil, errors.New("dirhash: inclusion proof too long")
+		}
+		if fn&1 == 1 || fn == sn {
+			r = merkleNode(h, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = merkleNode(r, h)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 {
+		return nil, errors.New("dirhash: inclusion proof too short")
+	}
+	return r, nil
+}
+
+func merkleLeaf(meta string, path string, digest []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	_, _ = io.WriteString(h, entryLine(meta, hex.EncodeToString(digest), path))
+	return h.Sum(nil)
+}
+
+func merkleNode(left []byte, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// merkleSplit returns the largest power of two smaller than n
+func merkleSplit(n int) int {
+	return 1 << (bits.Len(uint(n-1)) - 1)
+}
+
+func merkleRoot(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		h := sha256.Sum256(nil)
+		return h[:]
+	case 1:
+		return leaves[0]
+	}
+	k := merkleSplit(len(leaves))
+	return merkleNode(merkleRoot(leaves[:k]), merkleRoot(leaves[k:]))
+}
+
+func merklePath(index int, leaves [][]byte) [][]byte {
+	if len(leaves) <= 1 {
+		return nil
+	}
+	k := merkleSplit(len(leaves))
+	if index < k {
+		return append(merklePath(index, leaves[:k]), merkleRoot(leaves[k:]))
+	}
+	return append(merklePath(index-k, leaves[k:]), merkleRoot(leaves[:k]))
+}
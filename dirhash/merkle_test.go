@@ -0,0 +1,122 @@
+/*
+ * File: merkle_test.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package dirhash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMerkleProofs(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		files := make(map[string]string)
+		var names []string
+		for i := 0; i < n; i++ {
+			name := fmt.Sprintf("dir/f%02d.txt", i)
+			files[name] = strings.Repeat("x", i)
+			names = append(names, name)
+		}
+		hash, err := HashMerkle(names, memOpen(files))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := &Manifest{}
+		for _, name := range names {
+			m.Files = append(m.Files, manifestEntryOf(name, files[name]))
+		}
+		tree, err := m.MerkleTree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tree.Hash() != hash {
+			t.Fatalf("n=%d: manifest tree %s, HashMerkle %s", n, tree.Hash(), hash)
+		}
+		for _, name := range names {
+			p, err := tree.Proof(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = p.VerifyFile(strings.NewReader(files[name]), hash); err != nil {
+				t.Errorf("n=%d %s: %v", n, name, err)
+			}
+			if err = p.VerifyFile(strings.NewReader(files[name]+"!"), hash); err == nil {
+				t.Errorf("n=%d %s: modified file accepted", n, name)
+			}
+			if n > 1 {
+				p.Index = (p.Index + 1) % n
+				if err = p.Verify(hash); err == nil {
+					t.Errorf("n=%d %s: proof with another index accepted", n, name)
+				}
+			}
+		}
+	}
+}
+
+func TestMerkleProofUnknownFile(t *testing.T) {
+	tree := NewMerkleTree(map[string][]byte{"a.txt": make([]byte, 32)})
+	if _, err := tree.Proof("b.txt"); err == nil {
+		t.Error("proof of a file outside the tree")
+	}
+}
+
+func TestDirMerkleTree(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "hello\n")
+	writeFile(t, filepath.Join(dir, "sub", "b.txt"), "world\n")
+	writeFile(t, filepath.Join(dir, "skip.store"), "x")
+
+	tree, err := DirMerkleTree(dir, "", []string{"skip.store"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := HashDir(dir, "", []string{"skip.store"}, HashMerkle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Hash() != hash || !strings.HasPrefix(hash, SchemeM1+":") {
+		t.Errorf("DirMerkleTree %s, HashDir %s", tree.Hash(), hash)
+	}
+}
+
+func manifestEntryOf(name string, data string) ManifestEntry {
+	sum := sha256.Sum256([]byte(data))
+	return ManifestEntry{Path: name, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])}
+}
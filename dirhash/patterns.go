@@ -0,0 +1,205 @@
+/*
+ * File: patterns.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package dirhash
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"strings"
+)
+
+// IgnoreFile holds the exclude patterns of a folder, one per line
+const IgnoreFile = ".mrsignignore"
+
+// Patterns select the files of a folder with gitignore style globs: a file
+// is hashed when it matches an Include pattern (or there are none) and is not
+// excluded. The last Exclude pattern matching a path decides, a leading !
+// includes it again. A pattern ending with / matches only directories, a
+// pattern with another / is relative to the folder, otherwise it matches
+// the names at any depth. * and ? do not match /, ** matches any number of
+// directories. The files of an excluded directory are never hashed.
+type Patterns struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+type rule struct {
+	negate   bool
+	dirOnly  bool
+	anchored bool
+	parts    []string
+}
+
+func parseRule(pattern string) (rule, bool) {
+	var r rule
+	pattern = strings.TrimRight(pattern, " \t\r")
+	if len(pattern) == 0 || strings.HasPrefix(pattern, "#") {
+		return r, false
+	}
+	if strings.HasPrefix(pattern, "!") {
+		r.negate = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, `\`) {
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		r.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if strings.Contains(pattern, "/") {
+		r.anchored = true
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+	if len(pattern) == 0 {
+		return r, false
+	}
+	r.parts = strings.Split(pattern, "/")
+	return r, true
+}
+
+func (r rule) match(name string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.parts[0], path.Base(name))
+		return ok
+	}
+	return matchParts(r.parts, strings.Split(name, "/"))
+}
+
+func matchParts(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchParts(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
+
+func compile(patterns []string) []rule {
+	var rules []rule
+	for _, p := range patterns {
+		if r, ok := parseRule(p); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// Validate checks the syntax of the patterns
+func (p *Patterns) Validate() error {
+	for _, r := range append(compile(p.Include), compile(p.Exclude)...) {
+		for _, part := range r.parts {
+			if _, err := path.Match(part, ""); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// matcher returns the functions deciding if a directory is walked and a file
+// hashed, name is the slash separated path relative to the folder
+func (p *Patterns) matcher() (walk func(name string) bool, hash func(name string) bool) {
+	include := compile(p.Include)
+	exclude := compile(p.Exclude)
+	excluded := func(name string, isDir bool) bool {
+		ret := false
+		for _, r := range exclude {
+			if r.match(name, isDir) {
+				ret = !r.negate
+			}
+		}
+		return ret
+	}
+	walk = func(name string) bool {
+		return !excluded(name, true)
+	}
+	hash = func(name string) bool {
+		if excluded(name, false) {
+			return false
+		}
+		if len(include) == 0 {
+			return true
+		}
+		for _, r := range include {
+			if r.negate {
+				continue
+			}
+			if r.match(name, false) {
+				return true
+			}
+			// a directory pattern includes the files below it
+			for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+				if r.match(dir, true) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return walk, hash
+}
+
+// ReadPatterns reads the patterns of an IgnoreFile
+func ReadPatterns(r io.Reader) ([]string, error) {
+	var patterns []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), " \t\r")
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, s.Err()
+}
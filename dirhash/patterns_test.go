@@ -0,0 +1,115 @@
+/*
+ * File: patterns_test.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package dirhash
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPatterns(t *testing.T) {
+	files := []string{
+		"a.txt",
+		"b.log",
+		"zclient.store",
+		"logs/c.log",
+		"logs/keep.log",
+		"sub/zclient.store",
+		"sub/deep/d.txt",
+		"tmp/e.txt",
+		"img/disk.E01",
+		"img/disk.E02",
+	}
+	tests := []struct {
+		name     string
+		patterns Patterns
+		want     []string
+	}{
+		{"none", Patterns{}, files},
+		{"basename", Patterns{Exclude: []string{"*.log"}}, []string{
+			"a.txt", "zclient.store", "sub/zclient.store", "sub/deep/d.txt", "tmp/e.txt", "img/disk.E01", "img/disk.E02"}},
+		{"negate", Patterns{Exclude: []string{"*.log", "!keep.log"}}, []string{
+			"a.txt", "zclient.store", "logs/keep.log", "sub/zclient.store", "sub/deep/d.txt", "tmp/e.txt", "img/disk.E01", "img/disk.E02"}},
+		{"anchored", Patterns{Exclude: []string{"/zclient.store"}}, []string{
+			"a.txt", "b.log", "logs/c.log", "logs/keep.log", "sub/zclient.store", "sub/deep/d.txt", "tmp/e.txt", "img/disk.E01", "img/disk.E02"}},
+		{"directory", Patterns{Exclude: []string{"tmp/", "logs"}}, []string{
+			"a.txt", "b.log", "zclient.store", "sub/zclient.store", "sub/deep/d.txt", "img/disk.E01", "img/disk.E02"}},
+		{"double star", Patterns{Exclude: []string{"sub/**/*.txt", "**/zclient.store"}}, []string{
+			"a.txt", "b.log", "logs/c.log", "logs/keep.log", "tmp/e.txt", "img/disk.E01", "img/disk.E02"}},
+		{"include", Patterns{Include: []string{"img/", "*.txt"}, Exclude: []string{"*.E02"}}, []string{
+			"a.txt", "sub/deep/d.txt", "tmp/e.txt", "img/disk.E01"}},
+	}
+	dir := t.TempDir()
+	for _, f := range files {
+		writeFile(t, filepath.Join(dir, filepath.FromSlash(f)), f)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := dirFiles(dir, "", walkOptions{patterns: &tt.patterns})
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := make(map[string]bool)
+			for _, f := range got {
+				names[f.name] = true
+			}
+			want := make(map[string]bool)
+			for _, f := range tt.want {
+				want[f] = true
+			}
+			if !reflect.DeepEqual(names, want) {
+				t.Errorf("got %v, want %v", names, want)
+			}
+		})
+	}
+}
+
+func TestReadPatterns(t *testing.T) {
+	got, err := ReadPatterns(strings.NewReader("# evidence\n*.tmp\n\n!keep.tmp  \r\ncache/\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"*.tmp", "!keep.tmp", "cache/"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadPatterns() = %q, want %q", got, want)
+	}
+	if err = (&Patterns{Exclude: []string{"[a-"}}).Validate(); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
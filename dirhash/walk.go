@@ -0,0 +1,347 @@
+/*
+ * File: walk.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package dirhash
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Symbolic link policies
+const (
+	// SymlinkFollow hashes the target, walking the linked directories, when
+	// it is in the hashed folder, and records the links pointing out of it as
+	// SymlinkLink does
+	SymlinkFollow = "follow"
+	// SymlinkFollowAll hashes the target wherever it is
+	SymlinkFollowAll = "follow-all"
+	// SymlinkLink hashes the link target path, not its content
+	SymlinkLink = "link"
+	// SymlinkSkip leaves the links out
+	SymlinkSkip = "skip"
+)
+
+// Special file (socket, FIFO, device) policies
+const (
+	SpecialFail = "fail"
+	SpecialSkip = "skip"
+)
+
+// SkippedFile is a file left out by a policy, reported in the manifest
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+type dirFile struct {
+	name string
+	size int64
+	info os.FileInfo
+	// link is the target of a symbolic link, followed or recorded
+	link string
+	// id identifies the hard linked files
+	id     fileID
+	linked bool
+}
+
+// fileID is the device and inode of a file
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// walkOptions select the files of a folder
+type walkOptions struct {
+	exclude  map[string]bool
+	patterns *Patterns
+	metadata bool
+	symlinks string
+	special  string
+}
+
+// DefaultSymlinks returns the symbolic link policy of the signatures without
+// one: follow-all, as the walk before the policies, link with metadata
+func DefaultSymlinks(metadata bool) string {
+	if metadata {
+		return SymlinkLink
+	}
+	return SymlinkFollowAll
+}
+
+// policies returns the symbolic link and special file policies, the defaults
+// when empty
+func policies(symlinks string, special string, metadata bool) (string, string, error) {
+	switch symlinks {
+	case "":
+		symlinks = DefaultSymlinks(metadata)
+	case SymlinkFollow, SymlinkFollowAll, SymlinkLink, SymlinkSkip:
+	default:
+		return "", "", fmt.Errorf("dirhash: unknown symlink policy %q", symlinks)
+	}
+	switch special {
+	case "":
+		special = SpecialFail
+	case SpecialFail, SpecialSkip:
+	default:
+		return "", "", fmt.Errorf("dirhash: unknown special file policy %q", special)
+	}
+	return symlinks, special, nil
+}
+
+type walker struct {
+	walkOptions
+	prefix  string
+	walk    func(name string) bool
+	hash    func(name string) bool
+	files   []dirFile
+	dirs    []dirFile
+	skipped []SkippedFile
+	parents map[string]bool
+	// root is the real path of the hashed folder
+	root string
+	// linked are the IDs of the directories walked through a symbolic link
+	linked map[string]bool
+}
+
+// dirFiles lists the files of dir but the ones named in exclude and the ones
+// the patterns (optional) leave out, applying the symbolic link and special
+// file policies. With metadata it lists also the directories with nothing
+// listed.
+func dirFiles(dir string, prefix string, opts walkOptions) ([]dirFile, []SkippedFile, error) {
+	var err error
+	if opts.symlinks, opts.special, err = policies(opts.symlinks, opts.special, opts.metadata); err != nil {
+		return nil, nil, err
+	}
+	w := &walker{
+		walkOptions: opts,
+		prefix:      prefix,
+		walk:        func(string) bool { return true },
+		hash:        func(string) bool { return true },
+		parents:     make(map[string]bool),
+		linked:      make(map[string]bool),
+	}
+	if opts.patterns != nil {
+		w.walk, w.hash = opts.patterns.matcher()
+	}
+	dir = filepath.Clean(dir)
+	if w.root, err = filepath.EvalSymlinks(dir); err != nil {
+		return nil, nil, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, err := dirID(dir, info)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = w.walkDir(dir, "", []string{id}); err != nil {
+		return nil, nil, err
+	}
+	for _, d := range w.dirs {
+		if !w.parents[d.name] {
+			w.files = append(w.files, d)
+		}
+	}
+	sort.Slice(w.skipped, func(i, j int) bool { return w.skipped[i].Path < w.skipped[j].Path })
+	return w.files, w.skipped, nil
+}
+
+// walkDir lists the directory at abs, ancestors are the IDs of the
+// directories walked to get there
+func (w *walker) walkDir(abs string, rel string, ancestors []string) error {
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		childAbs := filepath.Join(abs, entry.Name())
+		childRel := path.Join(rel, entry.Name())
+		info, err := os.Lstat(childAbs)
+		if err != nil {
+			return err
+		}
+		if err = w.add(childAbs, childRel, info, ancestors); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *walker) add(abs string, rel string, info os.FileInfo, ancestors []string) error {
+	mode := info.Mode()
+	if mode.IsDir() {
+		return w.addDir(abs, rel, info, ancestors, false)
+	}
+	if w.exclude[info.Name()] || !w.hash(rel) {
+		return nil
+	}
+	switch {
+	case mode.IsRegular():
+		w.addFile(rel, info, "")
+	case mode&os.ModeSymlink != 0:
+		target, err := os.Readlink(abs)
+		if err != nil {
+			return err
+		}
+		switch w.symlinks {
+		case SymlinkSkip:
+			w.skip(rel, "symlink", "symlink policy "+SymlinkSkip)
+		case SymlinkLink:
+			w.addFile(rel, info, target)
+		case SymlinkFollow, SymlinkFollowAll:
+			tinfo, err := os.Stat(abs)
+			if err != nil {
+				return fmt.Errorf("dirhash: broken symbolic link %s: %s", rel, err.Error())
+			}
+			if w.symlinks == SymlinkFollow {
+				inside, err := w.inside(abs)
+				if err != nil {
+					return err
+				}
+				if !inside {
+					// what is out of the evidence is not signed, the link is
+					w.addFile(rel, info, target)
+					return nil
+				}
+			}
+			if tinfo.IsDir() {
+				return w.addDir(abs, rel, tinfo, ancestors, true)
+			}
+			if !tinfo.Mode().IsRegular() {
+				return w.addSpecial(rel, tinfo)
+			}
+			w.addFile(rel, tinfo, target)
+		}
+	default:
+		return w.addSpecial(rel, info)
+	}
+	return nil
+}
+
+// addDir walks the directory at abs, reached through a symbolic link when
+// viaLink. A directory is walked through the links once, so that links to the
+// same directories cannot multiply the walk.
+func (w *walker) addDir(abs string, rel string, info os.FileInfo, ancestors []string, viaLink bool) error {
+	if !w.walk(rel) {
+		return nil
+	}
+	id, err := dirID(abs, info)
+	if err != nil {
+		return err
+	}
+	for _, a := range ancestors {
+		if a == id {
+			w.skip(rel, "symlink", "symlink loop")
+			return nil
+		}
+	}
+	if viaLink {
+		if w.linked[id] {
+			w.skip(rel, "symlink", "directory already walked through a symlink")
+			return nil
+		}
+		w.linked[id] = true
+	}
+	if w.metadata && w.hash(rel) {
+		w.dirs = append(w.dirs, dirFile{name: path.Join(w.prefix, rel), info: info})
+	}
+	return w.walkDir(abs, rel, append(ancestors[:len(ancestors):len(ancestors)], id))
+}
+
+// inside tells whether the symbolic link at abs points into the hashed folder
+func (w *walker) inside(abs string) (bool, error) {
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(w.root, real)
+	if err != nil {
+		return false, nil
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)), nil
+}
+
+func (w *walker) addFile(rel string, info os.FileInfo, link string) {
+	name := filepath.ToSlash(filepath.Join(w.prefix, filepath.FromSlash(rel)))
+	f := dirFile{name: name, size: info.Size(), info: info, link: link}
+	if info.Mode().IsRegular() {
+		f.id, f.linked = inode(info)
+	}
+	w.files = append(w.files, f)
+	if w.metadata {
+		for d := path.Dir(name); d != "." && d != "/" && !w.parents[d]; d = path.Dir(d) {
+			w.parents[d] = true
+		}
+	}
+}
+
+func (w *walker) addSpecial(rel string, info os.FileInfo) error {
+	if w.special == SpecialSkip {
+		w.skip(rel, specialType(info.Mode()), "special file policy "+SpecialSkip)
+		return nil
+	}
+	return fmt.Errorf("dirhash: special file %s (%s)", rel, specialType(info.Mode()))
+}
+
+func (w *walker) skip(rel string, fileType string, reason string) {
+	w.skipped = append(w.skipped, SkippedFile{
+		Path:   path.Join(w.prefix, rel),
+		Type:   fileType,
+		Reason: reason,
+	})
+}
+
+func specialType(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "char device"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	default:
+		return "irregular"
+	}
+}
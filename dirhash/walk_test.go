@@ -0,0 +1,230 @@
+//go:build unix
+
+/*
+ * File: walk_test.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package dirhash
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWalkPolicies(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "a")
+	writeFile(t, filepath.Join(dir, "sub", "b.txt"), "b")
+	if err := os.Symlink("sub", filepath.Join(dir, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("..", filepath.Join(dir, "sub", "up")); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mkfifo(filepath.Join(dir, "fifo"), 0644); err != nil {
+		t.Skip("mkfifo:", err)
+	}
+
+	names := func(m *Manifest) []string {
+		var l []string
+		for _, f := range m.Files {
+			l = append(l, f.Path)
+		}
+		return l
+	}
+
+	// A FIFO fails by default, it must not be opened
+	done := make(chan error, 1)
+	go func() {
+		_, err := (&Hasher{}).Manifest(dir, "", nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("FIFO hashed")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("hung on the FIFO")
+	}
+
+	m, err := (&Hasher{Special: SpecialSkip}).Manifest(dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.txt", "linkdir/b.txt", "sub/b.txt"}; !reflect.DeepEqual(names(m), want) {
+		t.Fatalf("follow: got %v, want %v", names(m), want)
+	}
+	skipped := []SkippedFile{
+		{Path: "fifo", Type: "fifo", Reason: "special file policy skip"},
+		{Path: "linkdir/up", Type: "symlink", Reason: "symlink loop"},
+		{Path: "sub/up", Type: "symlink", Reason: "symlink loop"},
+	}
+	if !reflect.DeepEqual(m.Skipped, skipped) {
+		t.Fatalf("skipped: got %v, want %v", m.Skipped, skipped)
+	}
+
+	m, err = (&Hasher{Symlinks: SymlinkLink, Special: SpecialSkip}).Manifest(dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.txt", "linkdir", "sub/b.txt", "sub/up"}; !reflect.DeepEqual(names(m), want) {
+		t.Fatalf("link: got %v, want %v", names(m), want)
+	}
+	if m.Files[1].Type != EntrySymlink || m.Files[1].Link != "sub" {
+		t.Fatalf("link: got %+v", m.Files[1])
+	}
+
+	m, err = (&Hasher{Symlinks: SymlinkSkip, Special: SpecialSkip}).Manifest(dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.txt", "sub/b.txt"}; !reflect.DeepEqual(names(m), want) {
+		t.Fatalf("skip: got %v, want %v", names(m), want)
+	}
+	if len(m.Skipped) != 3 {
+		t.Fatalf("skip: got %v", m.Skipped)
+	}
+
+	if _, err = (&Hasher{Symlinks: "copy"}).Manifest(dir, "", nil); err == nil {
+		t.Fatal("unknown policy accepted")
+	}
+}
+
+func TestWalkSymlinkOutside(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	writeFile(t, filepath.Join(outside, "secret.txt"), "s")
+	writeFile(t, filepath.Join(dir, "a.txt"), "a")
+	if err := os.Symlink(outside, filepath.Join(dir, "out")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(dir, "secret")); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := (&Hasher{Symlinks: SymlinkFollow}).Manifest(dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 3 || m.Files[1].Path != "out" || m.Files[1].Type != EntrySymlink || m.Files[1].Link != outside ||
+		m.Files[2].Path != "secret" || m.Files[2].Type != EntrySymlink {
+		t.Fatalf("follow: got %+v", m.Files)
+	}
+
+	for _, symlinks := range []string{SymlinkFollowAll, ""} {
+		m, err = (&Hasher{Symlinks: symlinks}).Manifest(dir, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(m.Files) != 3 || m.Files[1].Path != "out/secret.txt" || m.Files[2].Path != "secret" || m.Files[2].Type == EntrySymlink {
+			t.Fatalf("%q: got %+v", symlinks, m.Files)
+		}
+	}
+}
+
+func TestWalkSymlinkVisited(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "sub", "b.txt"), "b")
+	for _, name := range []string{"l1", "l2"} {
+		if err := os.Symlink("sub", filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := (&Hasher{}).Manifest(dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range m.Files {
+		names = append(names, f.Path)
+	}
+	if want := []string{"l1/b.txt", "sub/b.txt"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("got %v, want %v", names, want)
+	}
+	skipped := []SkippedFile{{Path: "l2", Type: "symlink", Reason: "directory already walked through a symlink"}}
+	if !reflect.DeepEqual(m.Skipped, skipped) {
+		t.Fatalf("skipped: got %v, want %v", m.Skipped, skipped)
+	}
+}
+
+func TestWalkBrokenSymlink(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Symlink("missing", filepath.Join(dir, "broken")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&Hasher{}).Manifest(dir, "", nil); err == nil {
+		t.Fatal("broken link followed")
+	}
+	if _, err := (&Hasher{Symlinks: SymlinkLink}).Manifest(dir, "", nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWalkHardlinks(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "hello")
+	if err := os.Link(filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")); err != nil {
+		t.Skip("link:", err)
+	}
+	var last Progress
+	m, err := (&Hasher{Progress: func(p Progress) { last = p }}).Manifest(dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 2 {
+		t.Fatalf("got %d entries", len(m.Files))
+	}
+	a, b := m.Files[0], m.Files[1]
+	if b.Path != "b.txt" || b.HardlinkOf != "a.txt" || b.SHA256 != a.SHA256 || a.HardlinkOf != "" {
+		t.Fatalf("got %+v, %+v", a, b)
+	}
+	if last.TotalFiles != 1 || last.TotalBytes != 5 {
+		t.Fatalf("progress: got %+v", last)
+	}
+	h, err := (&Hasher{}).HashDir(dir, "", nil, SchemeH1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"a.txt": "hello", "b.txt": "hello"}
+	want, _ := Hash256([]string{"a.txt", "b.txt"}, memOpen(files))
+	if h != want {
+		t.Fatalf("got %s, want %s", h, want)
+	}
+}
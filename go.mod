@@ -0,0 +1,8 @@
+module github.com/zitelog/mrsign
+
+go 1.21
+
+require (
+	github.com/digitorus/pkcs7 v0.0.0-20250730155240-ffadbf3f398c
+	github.com/digitorus/timestamp v0.0.0-20250524132541-c45532741eea
+)
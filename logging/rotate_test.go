@@ -0,0 +1,103 @@
+/*
+ * File: rotate_test.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "mrsign.log")
+	f, err := OpenRotatingFile(fileName, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err = f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{fileName, "fourth\n"},
+		{fileName + ".1", "third\n"},
+		{fileName + ".2", "second\n"},
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, data, tt.want)
+		}
+	}
+	if _, err = os.Stat(fileName + ".3"); !os.IsNotExist(err) {
+		t.Error("too many backups kept")
+	}
+}
+
+func TestNew(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "mrsign.log")
+	logger, closer, err := New(fileName, "warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("challenge", "outcome", "signed")
+	logger.Warn("retrieve", "outcome", "mismatch", "key", "abc")
+	_ = closer.Close()
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "signed") || !strings.Contains(string(data), `"outcome":"mismatch"`) {
+		t.Errorf("unexpected log %s", data)
+	}
+	if _, _, err = New("", "verbose"); err == nil {
+		t.Error("unknown level accepted")
+	}
+}
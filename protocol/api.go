@@ -0,0 +1,83 @@
+/*
+ * File: api.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package protocol
+
+const (
+	ApiChallenge = "/v1/api/challenge"
+	ApiRetrieve  = "/v1/api/retrieve/"
+	ApiPublicKey = "/v1/api/publickey"
+	// ApiLedgerHead returns the signed head of the server ledger
+	ApiLedgerHead = "/v1/api/ledger/head"
+	// ApiSignatures lists the signatures, ApiSignatures/{sid} returns one
+	// and ApiSignatures/{sid}/revoke revokes it
+	ApiSignatures = "/v1/api/signatures"
+	ApiRevoke     = "/revoke"
+)
+
+// Query parameters of the challenge and retrieve negotiate requests
+const (
+	// ParamSupersede signs a new revision of an existing signature, its
+	// value is the reason
+	ParamSupersede = "supersede"
+	// ParamRevision verifies a revision of the signature, the latest when
+	// missing
+	ParamRevision = "revision"
+)
+
+// HeaderRequestID is the header of the ID the server gives every request
+const HeaderRequestID = "X-Request-Id"
+
+// Machine codes of the API errors
+const (
+	ErrorBadRequest       = "bad_request"
+	ErrorUnauthorized     = "unauthorized"
+	ErrorForbidden        = "forbidden"
+	ErrorInvalidSession   = "invalid_session"
+	ErrorNotFound         = "not_found"
+	ErrorMethodNotAllowed = "method_not_allowed"
+	ErrorConflict         = "conflict"
+	ErrorMismatch         = "mismatch"
+	ErrorRevoked          = "revoked"
+	ErrorInternal         = "internal"
+)
+
+// ErrorResponse is the JSON body of the API answers other than 200 OK
+type ErrorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"requestId"`
+}
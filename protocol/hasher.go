@@ -34,22 +34,43 @@
  * ----------	---	----------------------------------------------------------
  */
 
-package main
+package protocol
 
 import (
 	"bytes"
 	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/binary"
 	"fmt"
+	"hash"
 	"strings"
 )
 
-type avID uint16
+const (
+	MacMD5    = "hmac-md5"
+	MacSHA256 = "hmac-sha256"
+	MacSHA512 = "hmac-sha512"
+)
+
+var _macs = map[string]func() hash.Hash{
+	MacMD5:    md5.New,
+	MacSHA256: sha256.New,
+	MacSHA512: sha512.New,
+}
+
+var _macFlags = map[string]FlagsNegotiate{
+	MacMD5:    0,
+	MacSHA256: NegotiateFlagNEGOTIATEMACSHA256,
+	MacSHA512: NegotiateFlagNEGOTIATEMACSHA512,
+}
+
+type AvID uint16
 
 const (
-	avIDMsvAvEOL avID = iota
-	avIDMsvAvTimestamp
+	AvIDMsvAvEOL AvID = iota
+	AvIDMsvAvTimestamp
 )
 
 var _hasherZBlob = []byte{1, 1, 0, 0}
@@ -65,10 +86,24 @@ type ChallengeFields struct {
 
 type HasherZ struct {
 	Field ChallengeFields
+	mac   func() hash.Hash
 }
 
 func NewHasherZ() *HasherZ {
-	return &HasherZ{}
+	return &HasherZ{mac: md5.New}
+}
+
+// NewHasherZMac returns a HasherZ using the given MAC, HMAC-MD5 when mac is
+// empty as for the signatures stored before the MAC negotiation
+func NewHasherZMac(mac string) (*HasherZ, error) {
+	if len(mac) == 0 {
+		mac = MacMD5
+	}
+	h, ok := _macs[mac]
+	if !ok {
+		return nil, fmt.Errorf("unsupported mac: %s", mac)
+	}
+	return &HasherZ{mac: h}, nil
 }
 
 func (z *HasherZ) Parse(data []byte) error {
@@ -82,7 +117,7 @@ func (z *HasherZ) Parse(data []byte) error {
 
 func (z *HasherZ) CreateHash(key []byte, userName string, hostName string, folderName string) []byte {
 	data := toUnicode(strings.ToUpper(userName) + strings.ToUpper(hostName) + folderName)
-	return z.hmacMd5(key, data)
+	return z.hmac(key, data)
 }
 
 func (z *HasherZ) CreateResponse(hash, serverChallenge []byte, clientChallenge []byte, timestamp []byte) []byte {
@@ -97,12 +132,12 @@ func (z *HasherZ) CreateResponse(hash, serverChallenge []byte, clientChallenge [
 	temp = append(temp, clientChallenge...)
 	temp = append(temp, _hasherZReserved...)
 
-	res := z.hmacMd5(hash, serverChallenge, temp)
+	res := z.hmac(hash, serverChallenge, temp)
 	return append(res, temp...)
 }
 
-func (z *HasherZ) hmacMd5(key []byte, data ...[]byte) []byte {
-	mac := hmac.New(md5.New, key)
+func (z *HasherZ) hmac(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(z.mac, key)
 	for _, d := range data {
 		mac.Write(d)
 	}
@@ -34,10 +34,12 @@
  * ----------	---	----------------------------------------------------------
  */
 
-package main
+package protocol
 
 import (
 	"bytes"
+	"encoding/binary"
+	"fmt"
 )
 
 var signature = [8]byte{'H', 'A', 'S', 'H', 'E', 'R', 'Z', 0}
@@ -55,3 +57,14 @@ func (h Headers) IsValid() bool {
 	return bytes.Equal(h.Signature[:], signature[:]) &&
 		h.MessageType > 0 && h.MessageType < 4
 }
+
+func ReadHeaders(data []byte) (Headers, error) {
+	var h Headers
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &h); err != nil {
+		return h, err
+	}
+	if !h.IsValid() {
+		return h, fmt.Errorf("message is not a valid message: %+v", h)
+	}
+	return h, nil
+}
@@ -0,0 +1,96 @@
+/*
+ * File: ledgerhead.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package protocol
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+const _ledgerHeadVersion = "mrsign-ledger-head-v1"
+
+// LedgerHead is the head of the server ledger signed by the server. A copy
+// kept away from the server detects a ledger later truncated or rewritten
+// together with the signature store.
+type LedgerHead struct {
+	Seq       uint64 `json:"seq"`
+	Hash      string `json:"hash"`
+	Time      string `json:"time"`
+	KeyID     string `json:"keyId"`
+	Signature string `json:"signature"`
+}
+
+// SignedBytes returns the head fields covered by the server signature
+func (h LedgerHead) SignedBytes() []byte {
+	fields := []string{
+		_ledgerHeadVersion,
+		fmt.Sprint(h.Seq),
+		h.Hash,
+		h.Time,
+		h.KeyID,
+	}
+	b := bytes.Buffer{}
+	for _, f := range fields {
+		_, _ = fmt.Fprintf(&b, "%d:%s\n", len(f), f)
+	}
+	return b.Bytes()
+}
+
+func (h *LedgerHead) Sign(key ed25519.PrivateKey) {
+	h.KeyID = PublicKeyID(key.Public().(ed25519.PublicKey))
+	h.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, h.SignedBytes()))
+}
+
+func (h LedgerHead) VerifySignature(key ed25519.PublicKey) error {
+	if len(h.Signature) == 0 {
+		return errors.New("ledger head is not signed")
+	}
+	if h.KeyID != PublicKeyID(key) {
+		return errors.New("ledger head signed with a different key: " + h.KeyID)
+	}
+	sig, err := base64.StdEncoding.DecodeString(h.Signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, h.SignedBytes(), sig) {
+		return errors.New("invalid ledger head signature")
+	}
+	return nil
+}
@@ -34,12 +34,13 @@
  * ----------	---	----------------------------------------------------------
  */
 
-package main
+package protocol
 
 import (
 	"bytes"
 	"crypto/rand"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"time"
@@ -58,6 +59,10 @@ type MessageFieldsAuthenticate struct {
 	Flags FlagsNegotiate
 }
 
+func (m MessageFieldsAuthenticate) IsValid() bool {
+	return m.Headers.IsValid() && m.MessageType == 3
+}
+
 type MessageAuthenticate struct {
 	Hash []byte
 	UUId []byte
@@ -74,7 +79,7 @@ func NewMessageAuthenticate() *MessageAuthenticate {
 }
 
 func (am *MessageAuthenticate) Marshal() ([]byte, error) {
-	if !am.NegotiateFlags.Has(negotiateFlagNEGOTIATEUNICODE) {
+	if !am.NegotiateFlags.Has(NegotiateFlagNEGOTIATEUNICODE) {
 		return nil, errors.New("only unicode is supported")
 	}
 
@@ -87,7 +92,7 @@ func (am *MessageAuthenticate) Marshal() ([]byte, error) {
 		Timestamp: NewVarField(&ptr, len(am.Timestamp)),
 	}
 
-	am.Fields.Flags.Unset(negotiateFlagNEGOTIATEVERSION)
+	am.Fields.Flags.Unset(NegotiateFlagNEGOTIATEVERSION)
 
 	b := bytes.Buffer{}
 	if err := binary.Write(&b, binary.LittleEndian, &am.Fields); err != nil {
@@ -125,6 +130,11 @@ func (am *MessageAuthenticate) UnMarshal(data []byte) error {
 			return err
 		}
 	}
+	if am.Fields.Timestamp.Len > 0 {
+		if am.Timestamp, err = am.Fields.Timestamp.ReadFrom(data); err != nil {
+			return err
+		}
+	}
 	//if am.Fields.HostName.Len > 0 {
 	//	if am.HostName, err = am.Fields.HostName.ReadStringFrom(data); err != nil {
 	//		return err
@@ -138,21 +148,33 @@ func (am *MessageAuthenticate) UnMarshal(data []byte) error {
 	return nil
 }
 
-func (am *MessageAuthenticate) Build(cm *MessageChallenge) error {
+func (am *MessageAuthenticate) Build(cm *MessageChallenge, nm *NegotiateMessage, key []byte) error {
 	am.NegotiateFlags = cm.Fields.Flags
+	am.UUId = append([]byte(nil), cm.Fields.UUID[:]...)
 
-	am.Timestamp = cm.TargetInfo[avIDMsvAvTimestamp]
+	am.Timestamp = cm.TargetInfo[AvIDMsvAvTimestamp]
 	if am.Timestamp == nil {
 		ft := uint64(time.Now().UnixNano()) / 100
 		ft += 116444736000000000
 		am.Timestamp = make([]byte, 8)
 		binary.LittleEndian.PutUint64(am.Timestamp, ft)
 	}
-	am.ClientChallenge = make([]byte, _clientChallengeLen)
-	_, _ = rand.Reader.Read(am.ClientChallenge)
+	am.ClientChallenge = []byte(nm.ClientChallenge)
+	if len(am.ClientChallenge) == 0 {
+		am.ClientChallenge = make([]byte, _clientChallengeLen)
+		_, _ = rand.Reader.Read(am.ClientChallenge)
+	}
+
+	// the server challenge and the timestamp are hashed in their hex form,
+	// the same form kept in the server store
+	serverChallenge := []byte(hex.EncodeToString(cm.Fields.ServerChallenge[:]))
+	timestamp := []byte(hex.EncodeToString(am.Timestamp))
 
-	//hasher := NewHasherZ()
-	//hash := hasher.CreateHash(am.Key, am.UserName, am.HostName, am.FolderName)
-	//am.ChallengeResponse = hasher.CreateResponse(hash, cm.Fields.ServerChallenge[:], am.ClientChallenge, am.Timestamp)
+	hasher, err := NewHasherZMac(am.NegotiateFlags.Mac())
+	if err != nil {
+		return err
+	}
+	hash := hasher.CreateHash(key, nm.UserName, nm.HostName, nm.FolderName)
+	am.Hash = hasher.CreateResponse(hash, serverChallenge, am.ClientChallenge, timestamp)
 	return nil
 }
@@ -34,7 +34,7 @@
  * ----------	---	----------------------------------------------------------
  */
 
-package main
+package protocol
 
 import (
 	"bytes"
@@ -64,7 +64,7 @@ func (m MessageFieldsChallenge) IsValid() bool {
 
 type MessageChallenge struct {
 	Fields        MessageFieldsChallenge
-	TargetInfo    map[avID][]byte
+	TargetInfo    map[AvID][]byte
 	TargetInfoRaw []byte
 }
 
@@ -87,16 +87,16 @@ func (cm *MessageChallenge) Unmarshal(data []byte) error {
 		if err != nil {
 			return err
 		}
-		cm.TargetInfo = make(map[avID][]byte)
+		cm.TargetInfo = make(map[AvID][]byte)
 		r := bytes.NewReader(d)
 		for {
-			var id avID
+			var id AvID
 			var l uint16
 			err = binary.Read(r, binary.LittleEndian, &id)
 			if err != nil {
 				return err
 			}
-			if id == avIDMsvAvEOL {
+			if id == AvIDMsvAvEOL {
 				break
 			}
 			err = binary.Read(r, binary.LittleEndian, &l)
@@ -127,7 +127,7 @@ func (cm *MessageChallenge) Marshal() ([]byte, error) {
 			targetInfoLen += binary.Size(size)
 			targetInfoLen += binary.Size(val)
 		}
-		targetInfoLen += binary.Size(avIDMsvAvEOL)
+		targetInfoLen += binary.Size(AvIDMsvAvEOL)
 	}
 	ptr := binary.Size(&MessageFieldsChallenge{})
 	cm.Fields = MessageFieldsChallenge{
@@ -155,7 +155,7 @@ func (cm *MessageChallenge) Marshal() ([]byte, error) {
 				return nil, err
 			}
 		}
-		eof2 := avIDMsvAvEOL
+		eof2 := AvIDMsvAvEOL
 		if err := binary.Write(&raw, binary.LittleEndian, &eof2); err != nil {
 			return nil, err
 		}
@@ -169,7 +169,10 @@ func (cm *MessageChallenge) Marshal() ([]byte, error) {
 
 func (cm *MessageChallenge) Build(nm *NegotiateMessage) error {
 	cm.Fields.Flags = nm.Fields.Flags
-	cm.Fields.Flags.Set(negotiateFlagNEGOTIATEUNICODE)
+	cm.Fields.Flags.Set(NegotiateFlagNEGOTIATEUNICODE)
+	if err := cm.Fields.Flags.SetMac(nm.Fields.Flags.Mac()); err != nil {
+		return err
+	}
 
 	cm.Fields.UUID = NextUUID()
 
@@ -190,7 +193,7 @@ func (cm *MessageChallenge) Build(nm *NegotiateMessage) error {
 	ft += 116444736000000000
 	timestamp := make([]byte, _timestampLen)
 	binary.LittleEndian.PutUint64(timestamp, ft)
-	cm.TargetInfo = make(map[avID][]byte)
-	cm.TargetInfo[avIDMsvAvTimestamp] = timestamp
+	cm.TargetInfo = make(map[AvID][]byte)
+	cm.TargetInfo[AvIDMsvAvTimestamp] = timestamp
 	return nil
 }
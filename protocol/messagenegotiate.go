@@ -34,7 +34,7 @@
  * ----------	---	----------------------------------------------------------
  */
 
-package main
+package protocol
 
 import (
 	"bytes"
@@ -59,7 +59,7 @@ type MessageFieldsNegotiate struct {
 	Version
 }
 
-const defaultFlags = negotiateFlagNEGOTIATETARGETINFO | negotiateFlagNEGOTIATEUNICODE
+const defaultFlags = NegotiateFlagNEGOTIATETARGETINFO | NegotiateFlagNEGOTIATEUNICODE
 
 type NegotiateMessage struct {
 	UserName        string
@@ -67,6 +67,7 @@ type NegotiateMessage struct {
 	FolderName      string
 	Hash            string
 	ClientChallenge string
+	Flags           FlagsNegotiate
 	Fields          MessageFieldsNegotiate
 }
 
@@ -84,15 +85,15 @@ func (nm *NegotiateMessage) Marshal() ([]byte, error) {
 	hostName := strings.ToUpper(nm.HostName)
 
 	payloadOffset := expMsgBodyLen
-	flags := defaultFlags
+	flags := defaultFlags | nm.Flags
 	if len(nm.UserName) > 0 {
-		flags |= negotiateFlagNEGOTIATEUSERNAMESUPPLIED
+		flags |= NegotiateFlagNEGOTIATEUSERNAMESUPPLIED
 	}
 	if len(nm.HostName) > 0 {
-		flags |= negotiateFlagNEGOTIATEHOSTNAMESUPPLIED
+		flags |= NegotiateFlagNEGOTIATEHOSTNAMESUPPLIED
 	}
 	if len(nm.FolderName) > 0 {
-		flags |= negotiateFlagNEGOTIATFOLDERNAMESUPPLIED
+		flags |= NegotiateFlagNEGOTIATFOLDERNAMESUPPLIED
 	}
 
 	nm.Fields = MessageFieldsNegotiate{
@@ -136,6 +137,7 @@ func (nm *NegotiateMessage) Unmarshal(in []byte) error {
 	if !nm.IsValid() {
 		return fmt.Errorf("message is not a valid challenge message: %+v", nm.Fields.Headers)
 	}
+	nm.Flags = nm.Fields.Flags
 	if nm.Fields.UserName.Len > 0 {
 		if nm.UserName, err = nm.Fields.UserName.ReadStringFrom(in); err != nil {
 			return err
@@ -0,0 +1,265 @@
+/*
+ * File: messages_test.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package protocol
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestNegotiateMessageRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       NegotiateMessage
+		userName string
+		hostName string
+		flags    FlagsNegotiate
+	}{
+		{
+			name:     "all fields",
+			in:       NegotiateMessage{UserName: "bob", HostName: "pc01", FolderName: "/evidence/case-1", ClientChallenge: "00ff"},
+			userName: "BOB",
+			hostName: "PC01",
+			flags:    defaultFlags | NegotiateFlagNEGOTIATEUSERNAMESUPPLIED | NegotiateFlagNEGOTIATEHOSTNAMESUPPLIED | NegotiateFlagNEGOTIATFOLDERNAMESUPPLIED,
+		},
+		{
+			name:  "empty",
+			in:    NegotiateMessage{},
+			flags: defaultFlags,
+		},
+		{
+			name:     "unicode folder",
+			in:       NegotiateMessage{UserName: "zito", FolderName: "C:\\prove\\perizia è", Hash: "h1:abc"},
+			userName: "ZITO",
+			flags:    defaultFlags | NegotiateFlagNEGOTIATEUSERNAMESUPPLIED | NegotiateFlagNEGOTIATFOLDERNAMESUPPLIED,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.in.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := &NegotiateMessage{}
+			if err = out.Unmarshal(data); err != nil {
+				t.Fatal(err)
+			}
+			if out.UserName != tt.userName || out.HostName != tt.hostName {
+				t.Errorf("got user %q host %q, want %q %q", out.UserName, out.HostName, tt.userName, tt.hostName)
+			}
+			if out.FolderName != tt.in.FolderName || out.Hash != tt.in.Hash || out.ClientChallenge != tt.in.ClientChallenge {
+				t.Errorf("got %+v, want %+v", out, tt.in)
+			}
+			if out.Fields.Flags != tt.flags {
+				t.Errorf("got flags %x, want %x", out.Fields.Flags, tt.flags)
+			}
+			if out.CreateKey() != (&NegotiateMessage{UserName: tt.userName, HostName: tt.hostName, FolderName: tt.in.FolderName}).CreateKey() {
+				t.Error("key changed across the round trip")
+			}
+		})
+	}
+}
+
+func TestChallengeMessageRoundTrip(t *testing.T) {
+	nm := &NegotiateMessage{UserName: "bob", HostName: "pc01", FolderName: "/evidence"}
+	if _, err := nm.Marshal(); err != nil {
+		t.Fatal(err)
+	}
+	built := NewMessageChallenge()
+	if err := built.Build(nm); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		in   *MessageChallenge
+	}{
+		{name: "built", in: built},
+		{name: "no target info", in: &MessageChallenge{Fields: MessageFieldsChallenge{UUID: NextUUID()}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.in.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := NewMessageChallenge()
+			if err = out.Unmarshal(data); err != nil {
+				t.Fatal(err)
+			}
+			if out.Fields != tt.in.Fields {
+				t.Errorf("got fields %+v, want %+v", out.Fields, tt.in.Fields)
+			}
+			if !bytes.Equal(out.TargetInfo[AvIDMsvAvTimestamp], tt.in.TargetInfo[AvIDMsvAvTimestamp]) {
+				t.Errorf("got timestamp %x, want %x", out.TargetInfo[AvIDMsvAvTimestamp], tt.in.TargetInfo[AvIDMsvAvTimestamp])
+			}
+		})
+	}
+}
+
+func TestParseFiletime(t *testing.T) {
+	before := time.Now().Add(-time.Second)
+	cm := NewMessageChallenge()
+	if err := cm.Build(&NegotiateMessage{UserName: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseFiletime(hex.EncodeToString(cm.TargetInfo[AvIDMsvAvTimestamp]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Before(before) || got.After(time.Now().Add(time.Second)) {
+		t.Errorf("ParseFiletime() = %s, want about %s", got, before)
+	}
+	if _, err = ParseFiletime("a1cb"); err == nil {
+		t.Error("ParseFiletime() accepted a short timestamp")
+	}
+}
+
+func TestAuthenticateMessageRoundTrip(t *testing.T) {
+	nm := NewMessageNegotiate()
+	nm.UserName = "bob"
+	nm.HostName = "pc01"
+	nm.FolderName = "/evidence"
+	if _, err := nm.Marshal(); err != nil {
+		t.Fatal(err)
+	}
+	cm := NewMessageChallenge()
+	if err := cm.Build(nm); err != nil {
+		t.Fatal(err)
+	}
+	key := []byte("h1:cEiP8rChaw7Gg4phJDRj9Ep9kNhpIidz/E6YKYKlbIc=")
+
+	am := NewMessageAuthenticate()
+	if err := am.Build(cm, nm, key); err != nil {
+		t.Fatal(err)
+	}
+	data, err := am.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := NewMessageAuthenticate()
+	if err = out.UnMarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Hash, am.Hash) || !bytes.Equal(out.UUId, cm.Fields.UUID[:]) || !bytes.Equal(out.Timestamp, cm.TargetInfo[AvIDMsvAvTimestamp]) {
+		t.Errorf("got %+v, want %+v", out, am)
+	}
+
+	// the response must match the one the server used to compute from the
+	// hex encoded server challenge and timestamp
+	hasher := NewHasherZ()
+	hash := hasher.CreateHash(key, "BOB", "PC01", "/evidence")
+	want := hasher.CreateResponse(hash, []byte(hex.EncodeToString(cm.Fields.ServerChallenge[:])), []byte(nm.ClientChallenge), []byte(hex.EncodeToString(cm.TargetInfo[AvIDMsvAvTimestamp])))
+	if !bytes.Equal(out.Hash, want) {
+		t.Errorf("got response %x, want %x", out.Hash, want)
+	}
+}
+
+func TestUnmarshalWrongMessageType(t *testing.T) {
+	nm := &NegotiateMessage{UserName: "bob"}
+	data, err := nm.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = NewMessageChallenge().Unmarshal(data); err == nil {
+		t.Error("challenge accepted a negotiate message")
+	}
+	if err = NewMessageAuthenticate().UnMarshal(data); err == nil {
+		t.Error("authenticate accepted a negotiate message")
+	}
+	h, err := ReadHeaders(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.MessageType != 1 {
+		t.Errorf("got message type %d, want 1", h.MessageType)
+	}
+	if _, err = ReadHeaders([]byte("not a message")); err == nil {
+		t.Error("expected an error for an invalid message")
+	}
+}
+
+func TestMacNegotiation(t *testing.T) {
+	tests := []struct {
+		name    string
+		offered FlagsNegotiate
+		want    string
+		size    int
+	}{
+		{name: "legacy", offered: 0, want: MacMD5, size: 16},
+		{name: "sha256", offered: NegotiateFlagNEGOTIATEMACSHA256, want: MacSHA256, size: 32},
+		{name: "sha512", offered: NegotiateFlagNEGOTIATEMACSHA512, want: MacSHA512, size: 64},
+		{name: "strongest", offered: NegotiateFlagsMAC, want: MacSHA512, size: 64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nm := NewMessageNegotiate()
+			nm.UserName = "bob"
+			nm.Flags = tt.offered
+			data, err := nm.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			in := NewMessageNegotiate()
+			if err = in.Unmarshal(data); err != nil {
+				t.Fatal(err)
+			}
+			cm := NewMessageChallenge()
+			if err = cm.Build(in); err != nil {
+				t.Fatal(err)
+			}
+			if got := cm.Fields.Flags.Mac(); got != tt.want {
+				t.Errorf("got mac %s, want %s", got, tt.want)
+			}
+			if tt.want != MacMD5 && !cm.Fields.Flags.Has(_macFlags[tt.want]) || cm.Fields.Flags&NegotiateFlagsMAC != _macFlags[tt.want] {
+				t.Errorf("got flags %x", cm.Fields.Flags)
+			}
+			am := NewMessageAuthenticate()
+			if err = am.Build(cm, nm, []byte("h1:key")); err != nil {
+				t.Fatal(err)
+			}
+			if len(am.Hash) != tt.size+len(_hasherZBlob)+len(_hasherZReserved)*2+2*_timestampLen+len(nm.ClientChallenge) {
+				t.Errorf("got response of %d bytes", len(am.Hash))
+			}
+		})
+	}
+	if _, err := NewHasherZMac("hmac-sha1"); err == nil {
+		t.Error("unsupported mac accepted")
+	}
+}
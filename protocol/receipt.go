@@ -0,0 +1,214 @@
+/*
+ * File: receipt.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package protocol
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	_receiptVersion = "mrsign-receipt-v1"
+	// _receiptVersion2 signs also the revision, for the receipts with one
+	_receiptVersion2 = "mrsign-receipt-v2"
+)
+
+type Receipt struct {
+	SID             string `json:"sid"`
+	Key             string `json:"key"`
+	Timestamp       string `json:"timestamp"`
+	Digest          string `json:"digest"`
+	Algorithm       string `json:"algorithm,omitempty"`
+	User            string `json:"user,omitempty"`
+	HostName        string `json:"hostName,omitempty"`
+	Path            string `json:"path,omitempty"`
+	ServerChallenge string `json:"serverChallenge,omitempty"`
+	KeyID           string `json:"keyId,omitempty"`
+	Signature       string `json:"signature,omitempty"`
+	TimestampToken  []byte `json:"timestampToken,omitempty"`
+	// Revision of the signature of the key, 0 for the receipts issued before
+	// the revisions, which are the first one
+	Revision   int    `json:"revision,omitempty"`
+	Supersedes string `json:"supersedes,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// SignedBytes returns the receipt fields covered by the server signature,
+// the timestamp token is left out as it is signed by its TSA
+func (r Receipt) SignedBytes() []byte {
+	version := _receiptVersion
+	if r.Revision > 0 {
+		version = _receiptVersion2
+	}
+	fields := []string{
+		version,
+		r.SID,
+		r.Key,
+		r.Timestamp,
+		r.Digest,
+		r.Algorithm,
+		r.User,
+		r.HostName,
+		r.Path,
+		r.ServerChallenge,
+		r.KeyID,
+	}
+	if r.Revision > 0 {
+		fields = append(fields, fmt.Sprint(r.Revision), r.Supersedes, r.Reason)
+	}
+	b := bytes.Buffer{}
+	for _, f := range fields {
+		_, _ = fmt.Fprintf(&b, "%d:%s\n", len(f), f)
+	}
+	return b.Bytes()
+}
+
+func (r *Receipt) Sign(key ed25519.PrivateKey) {
+	r.KeyID = PublicKeyID(key.Public().(ed25519.PublicKey))
+	r.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, r.SignedBytes()))
+}
+
+func (r Receipt) VerifySignature(key ed25519.PublicKey) error {
+	if len(r.Signature) == 0 {
+		return errors.New("receipt is not signed")
+	}
+	if r.KeyID != PublicKeyID(key) {
+		return errors.New("receipt signed with a different key: " + r.KeyID)
+	}
+	sig, err := base64.StdEncoding.DecodeString(r.Signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, r.SignedBytes(), sig) {
+		return errors.New("invalid receipt signature")
+	}
+	return nil
+}
+
+// RevisionNumber returns the revision of the receipt, 1 for the receipts
+// without revision
+func (r Receipt) RevisionNumber() int {
+	if r.Revision == 0 {
+		return 1
+	}
+	return r.Revision
+}
+
+// ErrDifferentSignature is the error of a receipt that does not match the folder
+var ErrDifferentSignature = errors.New("different signature")
+
+// VerifyResult recomputes the signature result from the folder hash and the
+// client challenge and compares it with the receipt digest
+func (r Receipt) VerifyResult(folderHash string, clientChallenge string) error {
+	hasher, err := NewHasherZMac(r.Algorithm)
+	if err != nil {
+		return err
+	}
+	hash := hasher.CreateHash([]byte(folderHash), r.User, r.HostName, r.Path)
+	result := hasher.CreateResponse(hash, []byte(r.ServerChallenge), []byte(clientChallenge), []byte(r.Timestamp))
+	digest := sha256.Sum256(result)
+	if hex.EncodeToString(digest[:]) != r.Digest {
+		return ErrDifferentSignature
+	}
+	return nil
+}
+
+// VerifyTimestamp checks the RFC 3161 token of the receipt against its digest
+func (r Receipt) VerifyTimestamp(roots *x509.CertPool) (time.Time, error) {
+	if len(r.TimestampToken) == 0 {
+		return time.Time{}, errors.New("receipt without timestamp token")
+	}
+	digest, err := hex.DecodeString(r.Digest)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return VerifyTimestampToken(r.TimestampToken, digest, roots)
+}
+
+// Time returns the server timestamp of the receipt
+func (r Receipt) Time() (time.Time, error) {
+	return ParseFiletime(r.Timestamp)
+}
+
+// ParseFiletime decodes a server timestamp, a FILETIME in little endian hex
+func ParseFiletime(timestamp string) (time.Time, error) {
+	b, err := hex.DecodeString(timestamp)
+	if err != nil || len(b) != 8 {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	ft := binary.LittleEndian.Uint64(b) - 116444736000000000
+	return time.Unix(0, int64(ft)*100).UTC(), nil
+}
+
+func PublicKeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+func MarshalPublicKey(key ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || !strings.HasSuffix(block.Type, "PUBLIC KEY") {
+		return nil, errors.New("invalid public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not ed25519")
+	}
+	return pub, nil
+}
@@ -0,0 +1,196 @@
+/*
+ * File: signatures.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package protocol
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Default and maximum number of signatures of a SignatureList page
+const (
+	DefaultSignatureLimit = 100
+	MaxSignatureLimit     = 1000
+)
+
+// SignatureInfo is a signature of the server as listed by the API, without
+// the signature result and the server challenge
+type SignatureInfo struct {
+	SID          string      `json:"sid"`
+	Key          string      `json:"key"`
+	User         string      `json:"user"`
+	HostName     string      `json:"hostName"`
+	Path         string      `json:"path"`
+	Signed       time.Time   `json:"signed"`
+	Algorithm    string      `json:"algorithm"`
+	Metadata     bool        `json:"metadata,omitempty"`
+	Revision     int         `json:"revision"`
+	Supersedes   string      `json:"supersedes,omitempty"`
+	Reason       string      `json:"reason,omitempty"`
+	SupersededBy string      `json:"supersededBy,omitempty"`
+	KeyID        string      `json:"keyId,omitempty"`
+	Timestamped  bool        `json:"timestamped,omitempty"`
+	Revocation   *Revocation `json:"revocation,omitempty"`
+}
+
+// Reasons of a revocation
+const (
+	RevokeUnspecified          = "unspecified"
+	RevokeAcquisitionError     = "acquisition_error"
+	RevokeCredentialCompromise = "credential_compromise"
+)
+
+// ValidRevokeReason tells whether reason is one of the revocation reasons
+func ValidRevokeReason(reason string) bool {
+	switch reason {
+	case RevokeUnspecified, RevokeAcquisitionError, RevokeCredentialCompromise:
+		return true
+	}
+	return false
+}
+
+// RevokeRequest is the body of a revocation
+type RevokeRequest struct {
+	Reason  string `json:"reason"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// Revocation withdraws a signature: who revoked it, when and why
+type Revocation struct {
+	Reason  string    `json:"reason"`
+	Comment string    `json:"comment,omitempty"`
+	By      string    `json:"by"`
+	Time    time.Time `json:"time"`
+}
+
+// SignatureList is a page of the signatures matching a SignatureQuery, Total
+// counts all of them
+type SignatureList struct {
+	Signatures []SignatureInfo `json:"signatures"`
+	Total      int             `json:"total"`
+	Offset     int             `json:"offset"`
+	Limit      int             `json:"limit"`
+}
+
+// SignatureQuery selects the signatures of a SignatureList. User and Host
+// match ignoring the case, Path is a prefix and the signatures are those
+// signed from From included to To excluded, when set.
+type SignatureQuery struct {
+	User string
+	Host string
+	Path string
+	From time.Time
+	To   time.Time
+	// History lists also the superseded revisions
+	History bool
+	Offset  int
+	Limit   int
+}
+
+// Values returns the query parameters of q
+func (q SignatureQuery) Values() url.Values {
+	v := url.Values{}
+	set := func(name string, value string) {
+		if len(value) > 0 {
+			v.Set(name, value)
+		}
+	}
+	set("user", q.User)
+	set("host", q.Host)
+	set("path", q.Path)
+	if !q.From.IsZero() {
+		v.Set("from", q.From.Format(time.RFC3339Nano))
+	}
+	if !q.To.IsZero() {
+		v.Set("to", q.To.Format(time.RFC3339Nano))
+	}
+	if q.History {
+		v.Set("history", "true")
+	}
+	if q.Offset > 0 {
+		v.Set("offset", strconv.Itoa(q.Offset))
+	}
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	return v
+}
+
+// ParseSignatureQuery decodes the query parameters of a SignatureQuery,
+// bounding its limit
+func ParseSignatureQuery(v url.Values) (SignatureQuery, error) {
+	q := SignatureQuery{
+		User:  v.Get("user"),
+		Host:  v.Get("host"),
+		Path:  v.Get("path"),
+		Limit: DefaultSignatureLimit,
+	}
+	var err error
+	for _, t := range []struct {
+		name string
+		time *time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		if s := v.Get(t.name); len(s) > 0 {
+			if *t.time, err = time.Parse(time.RFC3339Nano, s); err != nil {
+				return q, fmt.Errorf("invalid %s: %s", t.name, s)
+			}
+		}
+	}
+	if s := v.Get("history"); len(s) > 0 {
+		if q.History, err = strconv.ParseBool(s); err != nil {
+			return q, fmt.Errorf("invalid history: %s", s)
+		}
+	}
+	for _, n := range []struct {
+		name  string
+		value *int
+	}{{"offset", &q.Offset}, {"limit", &q.Limit}} {
+		if s := v.Get(n.name); len(s) > 0 {
+			if *n.value, err = strconv.Atoi(s); err != nil || *n.value < 0 {
+				return q, fmt.Errorf("invalid %s: %s", n.name, s)
+			}
+		}
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultSignatureLimit
+	} else if q.Limit > MaxSignatureLimit {
+		q.Limit = MaxSignatureLimit
+	}
+	return q, nil
+}
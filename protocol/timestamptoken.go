@@ -0,0 +1,106 @@
+/*
+ * File: timestamptoken.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package protocol
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+	"github.com/digitorus/timestamp"
+)
+
+// ErrUntrustedTimestamp is returned with the time of a valid timestamp token
+// when there are no trusted TSA certificates to check its TSA against
+var ErrUntrustedTimestamp = errors.New("timestamp token not checked against trusted TSA certificates")
+
+// VerifyTimestampToken checks that the RFC 3161 token covers the SHA-256
+// digest and is signed by the TSA certificate it carries, which must chain to
+// one of roots. When roots is nil anyone could have issued the token: its
+// time is returned with ErrUntrustedTimestamp.
+func VerifyTimestampToken(token []byte, digest []byte, roots *x509.CertPool) (time.Time, error) {
+	ts, err := timestamp.Parse(token)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !ts.AddTSACertificate {
+		return time.Time{}, errors.New("timestamp token without TSA certificate")
+	}
+	if ts.HashAlgorithm != crypto.SHA256 || !bytes.Equal(ts.HashedMessage, digest) {
+		return time.Time{}, errors.New("timestamp token does not match the signature")
+	}
+	p7, err := pkcs7.Parse(token)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if roots == nil {
+		if err = p7.Verify(); err != nil {
+			return time.Time{}, err
+		}
+		return ts.Time, ErrUntrustedTimestamp
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   ts.Time,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}
+	for _, c := range p7.Certificates {
+		opts.Intermediates.AddCert(c)
+	}
+	if err = p7.VerifyWithOpts(opts); err != nil {
+		return time.Time{}, err
+	}
+	return ts.Time, nil
+}
+
+// LoadCertPool reads the PEM certificates of file
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("no certificate in " + file)
+	}
+	return pool, nil
+}
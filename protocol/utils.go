@@ -34,7 +34,7 @@
  * ----------	---	----------------------------------------------------------
  */
 
-package main
+package protocol
 
 import (
 	"crypto/sha256"
@@ -34,7 +34,7 @@
  * ----------	---	----------------------------------------------------------
  */
 
-package main
+package server
 
 import (
 	"encoding/json"
@@ -45,6 +45,8 @@ import (
 type UserConfig struct {
 	User string
 	Hash string
+	// Admin may revoke the signatures of every account
+	Admin bool
 }
 
 type UsersConfig struct {
@@ -58,11 +60,28 @@ type SecureConfig struct {
 	Key    string
 }
 
+type SigningConfig struct {
+	Enable bool
+	Key    string
+}
+
+type TSAConfig struct {
+	Enable bool
+	Url    string
+	Cert   string
+}
+
 type Config struct {
 	Listen              string
 	ServerStoreFilePath string
+	StoreType           string
 	Users               UsersConfig
 	Secure              SecureConfig
+	Signing             SigningConfig
+	TSA                 TSAConfig
+	// LedgerInit imports into a missing or empty ledger the signatures of
+	// the store, set by server -ledger-init and never by the config file
+	LedgerInit bool `json:"-"`
 }
 
 type Loader struct {
@@ -0,0 +1,224 @@
+/*
+ * File: journalstore.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+const ServerJournalFile = "zserver.journal"
+
+const (
+	journalOpPut    = "put"
+	journalOpDelete = "delete"
+)
+
+type journalRecord struct {
+	Op    string       `json:"op"`
+	Key   string       `json:"key"`
+	Store *ServerStore `json:"store,omitempty"`
+}
+
+// JournalStore appends every change to a log file, one JSON record per line,
+// and rebuilds the signatures replaying the log when opened
+type JournalStore struct {
+	mutex    sync.RWMutex
+	file     *os.File
+	data     map[string]ServerStore
+	readOnly bool
+}
+
+func NewJournalStore(fileName string) (*JournalStore, error) {
+	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s := &JournalStore{
+		file: f,
+		data: make(map[string]ServerStore),
+	}
+	if err = s.replay(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// readJournalStore returns the signatures of a journal the server may be
+// appending to, without changing it: a missing journal is empty and a record
+// being written at its end is left out. The store cannot be changed.
+func readJournalStore(fileName string) (*JournalStore, error) {
+	s := &JournalStore{
+		data:     make(map[string]ServerStore),
+		readOnly: true,
+	}
+	f, err := os.Open(fileName)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s.file = f
+	err = s.replay()
+	s.file = nil
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *JournalStore) replay() error {
+	r := bufio.NewReader(s.file)
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(data) > 0 && !s.readOnly {
+				// a torn write at the end of the log, the record was never acknowledged
+				return s.file.Truncate(s.size() - int64(len(data)))
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var record journalRecord
+		if err = json.Unmarshal(data, &record); err != nil {
+			return fmt.Errorf("journal: invalid record at line %d: %s", line, err.Error())
+		}
+		switch record.Op {
+		case journalOpPut:
+			if record.Store == nil {
+				return fmt.Errorf("journal: missing store at line %d", line)
+			}
+			s.data[record.Key] = *record.Store
+		case journalOpDelete:
+			delete(s.data, record.Key)
+		default:
+			return fmt.Errorf("journal: unknown operation %q at line %d", record.Op, line)
+		}
+	}
+}
+
+func (s *JournalStore) size() int64 {
+	info, err := s.file.Stat()
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+func (s *JournalStore) Put(key string, store ServerStore) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.append(journalRecord{Op: journalOpPut, Key: key, Store: &store}); err != nil {
+		return err
+	}
+	s.data[key] = store
+	return nil
+}
+
+func (s *JournalStore) Get(key string) (ServerStore, bool, error) {
+	s.mutex.RLock()
+	store, ok := s.data[key]
+	s.mutex.RUnlock()
+	return store, ok, nil
+}
+
+func (s *JournalStore) List() ([]ServerStore, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	out := make([]ServerStore, 0, len(s.data))
+	for _, store := range s.data {
+		out = append(out, store)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+func (s *JournalStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.data[key]; !ok {
+		return nil
+	}
+	if err := s.append(journalRecord{Op: journalOpDelete, Key: key}); err != nil {
+		return err
+	}
+	delete(s.data, key)
+	return nil
+}
+
+func (s *JournalStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.readOnly {
+		return nil
+	}
+	if s.file == nil {
+		return errors.New("journal: already closed")
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *JournalStore) append(record journalRecord) error {
+	if s.readOnly {
+		return errors.New("journal: read-only")
+	}
+	if s.file == nil {
+		return errors.New("journal: closed")
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if _, err = s.file.Write(data); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
@@ -0,0 +1,154 @@
+/*
+ * File: jsonstore.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+)
+
+// JsonStore keeps every signature in a single JSON object, rewritten on each change
+type JsonStore struct {
+	mutex    sync.RWMutex
+	fileName string
+	data     map[string]string
+}
+
+func NewJsonStore(fileName string) (*JsonStore, error) {
+	s := &JsonStore{
+		fileName: fileName,
+		data:     make(map[string]string),
+	}
+	r, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(r, &s.data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *JsonStore) Put(key string, store ServerStore) error {
+	data, err := json.Marshal(store)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	previous, ok := s.data[key]
+	s.data[key] = string(data)
+	if err = s.flush(); err != nil {
+		// the file was not replaced
+		if ok {
+			s.data[key] = previous
+		} else {
+			delete(s.data, key)
+		}
+	}
+	return err
+}
+
+func (s *JsonStore) Get(key string) (ServerStore, bool, error) {
+	var store ServerStore
+	s.mutex.RLock()
+	data, ok := s.data[key]
+	s.mutex.RUnlock()
+	if !ok {
+		return store, false, nil
+	}
+	if err := json.Unmarshal([]byte(data), &store); err != nil {
+		return store, false, err
+	}
+	return store, true, nil
+}
+
+func (s *JsonStore) List() ([]ServerStore, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	keys := make([]string, 0, len(s.data))
+	for key := range s.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	out := make([]ServerStore, 0, len(keys))
+	for _, key := range keys {
+		var store ServerStore
+		if err := json.Unmarshal([]byte(s.data[key]), &store); err != nil {
+			return nil, err
+		}
+		out = append(out, store)
+	}
+	return out, nil
+}
+
+func (s *JsonStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	previous, ok := s.data[key]
+	if !ok {
+		return nil
+	}
+	delete(s.data, key)
+	err := s.flush()
+	if err != nil {
+		s.data[key] = previous
+	}
+	return err
+}
+
+func (s *JsonStore) Close() error {
+	return nil
+}
+
+func (s *JsonStore) flush() error {
+	out, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+	tmp := s.fileName + ".tmp"
+	if err = ioutil.WriteFile(tmp, out, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.fileName)
+}
@@ -1,7 +1,7 @@
 /*
- * File: clientStore.go
+ * File: keygen_test.go
  * Project: mrsign
- * Created Date: Tuesday, October 19th 2021, 12:16:54 pm
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
  * Authors: Marcello Russo, Fabio Zito
  * -----
  * Last Modified:
@@ -34,26 +34,25 @@
  * ----------	---	----------------------------------------------------------
  */
 
-package main
+package server
 
-import "time"
+import (
+	"crypto/tls"
+	"path/filepath"
+	"testing"
+)
 
-const ClientStoreFile = "zclient.store"
-
-type ClientStore struct {
-	User            string `json:"user"`
-	HostName        string `json:"hostName"`
-	Path            string `json:"path"`
-	ClientChallenge string `json:"clientChallenge"`
-	Epoch           int64  `json:"epoch"`
-}
-
-func NewClientStore() *ClientStore {
-	return &ClientStore{
-		User:            "",
-		HostName:        "",
-		Path:            "",
-		ClientChallenge: "",
-		Epoch:           time.Now().UnixNano() / int64(time.Millisecond),
+func TestGenerateKeyPair(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, DefaultCertFile)
+	keyFile := filepath.Join(dir, DefaultKeyFile)
+	if err := GenerateKeyPair(certFile, keyFile, []string{"localhost", "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateKeyPair(certFile, keyFile, nil); err == nil {
+		t.Error("existing key pair overwritten")
 	}
 }
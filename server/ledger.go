@@ -0,0 +1,423 @@
+/*
+ * File: ledger.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package server
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ServerLedgerFile = "zserver.ledger"
+
+const (
+	ledgerOpPut    = "put"
+	ledgerOpDelete = "delete"
+	// ledgerOpImport records the signatures stored before the ledger
+	ledgerOpImport = "import"
+	// ledgerOpRevoke records a signature with its new revocation
+	ledgerOpRevoke = "revoke"
+)
+
+var _ledgerGenesis = strings.Repeat("0", 2*sha256.Size)
+
+// LedgerEntry is a change of the signature store, Hash commits to the entry
+// fields and to the Hash of the previous entry
+type LedgerEntry struct {
+	Seq   uint64          `json:"seq"`
+	Op    string          `json:"op"`
+	Key   string          `json:"key"`
+	Time  string          `json:"time"`
+	Store json.RawMessage `json:"store,omitempty"`
+	Prev  string          `json:"prev"`
+	Hash  string          `json:"hash"`
+}
+
+func (e LedgerEntry) ComputeHash() string {
+	fields := []string{
+		fmt.Sprint(e.Seq),
+		e.Op,
+		e.Key,
+		e.Time,
+		string(e.Store),
+		e.Prev,
+	}
+	h := sha256.New()
+	for _, f := range fields {
+		_, _ = fmt.Fprintf(h, "%d:%s\n", len(f), f)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type LedgerHead struct {
+	Seq  uint64
+	Hash string
+}
+
+// Ledger is the append-only, hash-chained history of the signature store
+type Ledger struct {
+	mutex sync.Mutex
+	file  *os.File
+	head  LedgerHead
+}
+
+func OpenLedger(fileName string) (*Ledger, error) {
+	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	l := &Ledger{
+		file: f,
+		head: LedgerHead{Hash: _ledgerGenesis},
+	}
+	torn, err := readLedger(f, func(e LedgerEntry) error {
+		l.head = LedgerHead{Seq: e.Seq, Hash: e.Hash}
+		return nil
+	})
+	if err == nil && torn > 0 {
+		// a torn write at the end of the ledger, the change was never stored
+		var info os.FileInfo
+		if info, err = f.Stat(); err == nil {
+			err = f.Truncate(info.Size() - torn)
+		}
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Ledger) Head() LedgerHead {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.head
+}
+
+func (l *Ledger) Append(op string, key string, store *ServerStore) error {
+	_, err := l.append(op, key, store)
+	return err
+}
+
+// append appends the entry and returns the function removing it, for a change
+// of the store that failed. The entry must be the last one when removed.
+func (l *Ledger) append(op string, key string, store *ServerStore) (func() error, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return nil, errors.New("ledger: closed")
+	}
+	e := LedgerEntry{
+		Seq:  l.head.Seq + 1,
+		Op:   op,
+		Key:  key,
+		Time: time.Now().UTC().Format(time.RFC3339Nano),
+		Prev: l.head.Hash,
+	}
+	if store != nil {
+		data, err := json.Marshal(store)
+		if err != nil {
+			return nil, err
+		}
+		e.Store = data
+	}
+	e.Hash = e.ComputeHash()
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	info, err := l.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size, head := info.Size(), l.head
+	undo := func() error {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		if l.file == nil {
+			return errors.New("ledger: closed")
+		}
+		if err := l.file.Truncate(size); err != nil {
+			return err
+		}
+		l.head = head
+		return l.file.Sync()
+	}
+	data = append(data, '\n')
+	if _, err = l.file.Write(data); err == nil {
+		err = l.file.Sync()
+	}
+	if err != nil {
+		// no torn entry left before the next one
+		_ = l.file.Truncate(size)
+		return nil, err
+	}
+	l.head = LedgerHead{Seq: e.Seq, Hash: e.Hash}
+	return undo, nil
+}
+
+func (l *Ledger) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return errors.New("ledger: already closed")
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// readLedger calls fn for every entry of the ledger and returns the length
+// of the torn write at its end, if any
+func readLedger(r io.Reader, fn func(e LedgerEntry) error) (int64, error) {
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			return int64(len(data)), nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		var e LedgerEntry
+		if err = json.Unmarshal(data, &e); err != nil {
+			return 0, fmt.Errorf("ledger: invalid entry at line %d: %s", line, err.Error())
+		}
+		if err = fn(e); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// ledgerStore records every change of a signature store in its ledger, the
+// entry removed when the store fails to change
+type ledgerStore struct {
+	SignatureStore
+	ledger *Ledger
+	// mutex keeps the entry of a change the last one until the change is stored
+	mutex sync.Mutex
+}
+
+// newLedgerStore records the changes of store in the ledger of fileName. A
+// missing or empty ledger next to a store with signatures is refused, as it
+// is what a deleted ledger looks like, unless init imports the signatures.
+func newLedgerStore(store SignatureStore, fileName string, init bool) (*ledgerStore, error) {
+	stores, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	refused := fmt.Errorf("ledger: %s is missing or empty but the store holds %d signatures, start the server with -ledger-init to import them", fileName, len(stores))
+	if len(stores) > 0 && !init {
+		if _, err = os.Stat(fileName); err != nil {
+			return nil, refused
+		}
+	}
+	ledger, err := OpenLedger(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if ledger.Head().Seq == 0 {
+		if len(stores) > 0 && !init {
+			err = refused
+		}
+		for i := 0; err == nil && i < len(stores); i++ {
+			err = ledger.Append(ledgerOpImport, stores[i].Key, &stores[i])
+		}
+		if err != nil {
+			_ = ledger.Close()
+			return nil, err
+		}
+	}
+	return &ledgerStore{SignatureStore: store, ledger: ledger}, nil
+}
+
+func (s *ledgerStore) Put(key string, store ServerStore) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	current, ok, err := s.SignatureStore.Get(key)
+	if err != nil {
+		return err
+	}
+	op := ledgerOpPut
+	if ok && revokes(current, store) {
+		op = ledgerOpRevoke
+	}
+	return s.record(op, key, &store, func() error {
+		return s.SignatureStore.Put(key, store)
+	})
+}
+
+func (s *ledgerStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok, err := s.SignatureStore.Get(key); err != nil || !ok {
+		return err
+	}
+	return s.record(ledgerOpDelete, key, nil, func() error {
+		return s.SignatureStore.Delete(key)
+	})
+}
+
+// record appends the entry of the change to the ledger and makes the change,
+// removing the entry when it fails
+func (s *ledgerStore) record(op string, key string, store *ServerStore, change func() error) error {
+	undo, err := s.ledger.append(op, key, store)
+	if err != nil {
+		return err
+	}
+	if err = change(); err != nil {
+		if uerr := undo(); uerr != nil {
+			return fmt.Errorf("%s; removing the ledger entry: %s", err.Error(), uerr.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// revokes tells whether next is current with a new revocation: the same
+// revision, with more revisions revoked
+func revokes(current ServerStore, next ServerStore) bool {
+	revoked := func(s ServerStore) int {
+		n := 0
+		for _, r := range s.History() {
+			if r.Revocation != nil {
+				n++
+			}
+		}
+		return n
+	}
+	return current.SID == next.SID && revoked(next) > revoked(current)
+}
+
+func (s *ledgerStore) Close() error {
+	err := s.SignatureStore.Close()
+	if lerr := s.ledger.Close(); err == nil {
+		err = lerr
+	}
+	return err
+}
+
+// VerifyLedger checks the hash chain of the ledger and that the signature
+// store holds exactly the signatures recorded in it, so that a rewrite,
+// reordering or deletion of the history is detected. The ledger must also
+// reach anchor, when not nil, a head recorded before: the ledger and the store
+// truncated together are detected only against it.
+func VerifyLedger(cfg *Config, anchor *LedgerHead) (LedgerHead, error) {
+	head := LedgerHead{Hash: _ledgerGenesis}
+	f, err := os.Open(cfg.ServerStoreFilePath + string(os.PathSeparator) + ServerLedgerFile)
+	if err != nil {
+		return head, err
+	}
+	defer f.Close()
+
+	// an incomplete entry at the end is being written by the server, or was
+	// torn by a crash and is cut when the server starts: it is left out
+	state := make(map[string]ServerStore)
+	_, err = readLedger(f, func(e LedgerEntry) error {
+		if e.Seq != head.Seq+1 {
+			return fmt.Errorf("ledger: entry %d found after entry %d", e.Seq, head.Seq)
+		}
+		if e.Prev != head.Hash {
+			return fmt.Errorf("ledger: entry %d does not follow the previous entry", e.Seq)
+		}
+		if e.Hash != e.ComputeHash() {
+			return fmt.Errorf("ledger: entry %d has been modified", e.Seq)
+		}
+		if anchor != nil && e.Seq == anchor.Seq && e.Hash != anchor.Hash {
+			return fmt.Errorf("ledger: entry %d differs from the recorded head", e.Seq)
+		}
+		switch e.Op {
+		case ledgerOpPut, ledgerOpImport, ledgerOpRevoke:
+			var store ServerStore
+			if err := json.Unmarshal(e.Store, &store); err != nil {
+				return fmt.Errorf("ledger: entry %d: %s", e.Seq, err.Error())
+			}
+			state[e.Key] = store
+		case ledgerOpDelete:
+			delete(state, e.Key)
+		default:
+			return fmt.Errorf("ledger: entry %d: unknown operation %q", e.Seq, e.Op)
+		}
+		head = LedgerHead{Seq: e.Seq, Hash: e.Hash}
+		return nil
+	})
+	if err != nil {
+		return head, err
+	}
+	if anchor != nil && head.Seq < anchor.Seq {
+		return head, fmt.Errorf("ledger: truncated at entry %d before the recorded head %d", head.Seq, anchor.Seq)
+	}
+
+	store, err := readSignatureStore(cfg)
+	if err != nil {
+		return head, err
+	}
+	defer store.Close()
+	stores, err := store.List()
+	if err != nil {
+		return head, err
+	}
+	for _, s := range stores {
+		recorded, ok := state[s.Key]
+		if !ok {
+			return head, fmt.Errorf("ledger: signature %s not recorded", s.Key)
+		}
+		if !reflect.DeepEqual(recorded, s) {
+			return head, fmt.Errorf("ledger: signature %s differs from the recorded one", s.Key)
+		}
+		delete(state, s.Key)
+	}
+	if len(state) > 0 {
+		missing := make([]string, 0, len(state))
+		for key := range state {
+			missing = append(missing, key)
+		}
+		sort.Strings(missing)
+		return head, fmt.Errorf("ledger: signature %s missing from the store", missing[0])
+	}
+	return head, nil
+}
@@ -0,0 +1,325 @@
+/*
+ * File: ledger_test.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zitelog/mrsign/protocol"
+)
+
+// newTestLedger stores a, b (deleted) and c and returns the config
+func newTestLedger(t *testing.T, storeType string) *Config {
+	t.Helper()
+	cfg := &Config{ServerStoreFilePath: t.TempDir(), StoreType: storeType}
+	s, err := NewSignatureStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if err = s.Put(key, ServerStore{Key: key, User: "USER-" + key, Result: []byte(key)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = s.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func editFile(t *testing.T, fileName string, edit func(lines [][]byte) [][]byte) {
+	t.Helper()
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if err = os.WriteFile(fileName, bytes.Join(edit(lines[:len(lines)-1]), nil), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyLedger(t *testing.T) {
+	cfg := newTestLedger(t, StoreTypeJson)
+	head, err := VerifyLedger(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Seq != 4 || len(head.Hash) != 64 {
+		t.Errorf("head = %+v", head)
+	}
+}
+
+func TestVerifyLedgerTampering(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		edit func(lines [][]byte) [][]byte
+		want string
+	}{
+		{"rewrite", ServerLedgerFile, func(lines [][]byte) [][]byte {
+			lines[0] = bytes.Replace(lines[0], []byte("USER-a"), []byte("USER-x"), 1)
+			return lines
+		}, "entry 1 has been modified"},
+		{"reorder", ServerLedgerFile, func(lines [][]byte) [][]byte {
+			lines[0], lines[1] = lines[1], lines[0]
+			return lines
+		}, "entry 2 found after entry 0"},
+		{"delete", ServerLedgerFile, func(lines [][]byte) [][]byte {
+			return append(lines[:1], lines[2:]...)
+		}, "entry 3 found after entry 1"},
+		{"truncate", ServerLedgerFile, func(lines [][]byte) [][]byte {
+			return lines[:2]
+		}, "signature c not recorded"},
+		{"store rewrite", ServerJournalFile, func(lines [][]byte) [][]byte {
+			lines[0] = bytes.Replace(lines[0], []byte("USER-a"), []byte("USER-x"), 1)
+			return lines
+		}, "signature a differs"},
+		{"store delete", ServerJournalFile, func(lines [][]byte) [][]byte {
+			return lines[1:]
+		}, "signature a missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestLedger(t, StoreTypeJournal)
+			editFile(t, filepath.Join(cfg.ServerStoreFilePath, tt.file), tt.edit)
+			_, err := VerifyLedger(cfg, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("VerifyLedger() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLedgerImport(t *testing.T) {
+	cfg := &Config{ServerStoreFilePath: t.TempDir()}
+	js, err := NewJsonStore(filepath.Join(cfg.ServerStoreFilePath, ServerStoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b"} {
+		if err = js.Put(key, ServerStore{Key: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a store without ledger looks like a deleted ledger
+	if _, err = NewSignatureStore(cfg); err == nil || !strings.Contains(err.Error(), "-ledger-init") {
+		t.Fatalf("NewSignatureStore() without ledger = %v", err)
+	}
+	cfg.LedgerInit = true
+	s, err := NewSignatureStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Put("c", ServerStore{Key: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Close()
+	head, err := VerifyLedger(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Seq != 3 {
+		t.Errorf("head.Seq = %d, want 3", head.Seq)
+	}
+}
+
+func TestLedgerDeleted(t *testing.T) {
+	cfg := newTestLedger(t, StoreTypeJournal)
+	if err := os.Remove(filepath.Join(cfg.ServerStoreFilePath, ServerLedgerFile)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSignatureStore(cfg); err == nil {
+		t.Fatal("store opened without its ledger")
+	}
+	// the refused start leaves no empty ledger behind
+	if _, err := os.Stat(filepath.Join(cfg.ServerStoreFilePath, ServerLedgerFile)); !os.IsNotExist(err) {
+		t.Errorf("ledger created by the refused start: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.ServerStoreFilePath, ServerLedgerFile), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSignatureStore(cfg); err == nil {
+		t.Fatal("store opened with an empty ledger")
+	}
+}
+
+func TestVerifyLedgerAnchor(t *testing.T) {
+	cfg := newTestLedger(t, StoreTypeJournal)
+	anchor, err := VerifyLedger(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = VerifyLedger(cfg, &anchor); err != nil {
+		t.Fatalf("VerifyLedger() at its head = %v", err)
+	}
+
+	// the last signature removed from the ledger and from the store alike
+	editFile(t, filepath.Join(cfg.ServerStoreFilePath, ServerLedgerFile), func(lines [][]byte) [][]byte {
+		return lines[:len(lines)-1]
+	})
+	editFile(t, filepath.Join(cfg.ServerStoreFilePath, ServerJournalFile), func(lines [][]byte) [][]byte {
+		return lines[:len(lines)-1]
+	})
+	if _, err = VerifyLedger(cfg, nil); err != nil {
+		t.Fatalf("VerifyLedger() of the consistent truncation = %v", err)
+	}
+	if _, err = VerifyLedger(cfg, &anchor); err == nil || !strings.Contains(err.Error(), "before the recorded head") {
+		t.Errorf("VerifyLedger() = %v, want the truncation", err)
+	}
+
+	// the ledger rewritten from the truncation point on
+	s, err := NewSignatureStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Put("d", ServerStore{Key: "d"}); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Close()
+	if _, err = VerifyLedger(cfg, &anchor); err == nil || !strings.Contains(err.Error(), "differs from the recorded head") {
+		t.Errorf("VerifyLedger() = %v, want the rewrite", err)
+	}
+}
+
+func TestVerifyLedgerTornEntry(t *testing.T) {
+	cfg := newTestLedger(t, StoreTypeJournal)
+	fileName := filepath.Join(cfg.ServerStoreFilePath, ServerLedgerFile)
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an entry the server is writing
+	_, _ = f.WriteString(`{"seq":5,"op":"put","key":"d","ti`)
+	_ = f.Close()
+	before, _ := os.ReadFile(fileName)
+
+	head, err := VerifyLedger(cfg, nil)
+	if err != nil || head.Seq != 4 {
+		t.Fatalf("VerifyLedger() = %+v, %v", head, err)
+	}
+	if after, _ := os.ReadFile(fileName); !bytes.Equal(before, after) {
+		t.Errorf("ledger changed by the verification: %q", after)
+	}
+}
+
+// failingStore fails every change while fail
+type failingStore struct {
+	SignatureStore
+	fail bool
+}
+
+func (s *failingStore) Put(key string, store ServerStore) error {
+	if s.fail {
+		return errors.New("disk full")
+	}
+	return s.SignatureStore.Put(key, store)
+}
+
+func (s *failingStore) Delete(key string) error {
+	if s.fail {
+		return errors.New("disk full")
+	}
+	return s.SignatureStore.Delete(key)
+}
+
+func TestLedgerStoreFailure(t *testing.T) {
+	cfg := &Config{ServerStoreFilePath: t.TempDir()}
+	store, err := openSignatureStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := &failingStore{SignatureStore: store}
+	s, err := newLedgerStore(fs, filepath.Join(cfg.ServerStoreFilePath, ServerLedgerFile), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := ServerStore{SID: "sid-a", Key: "a", Result: []byte("a")}
+	if err = s.Put("a", a); err != nil {
+		t.Fatal(err)
+	}
+	revoked, _ := a.Revoke("sid-a", protocol.Revocation{Reason: protocol.RevokeUnspecified})
+
+	fs.fail = true
+	for name, change := range map[string]func() error{
+		"put":    func() error { return s.Put("b", ServerStore{Key: "b"}) },
+		"revoke": func() error { return s.Put("a", revoked) },
+		"delete": func() error { return s.Delete("a") },
+	} {
+		if err = change(); err == nil {
+			t.Errorf("%s succeeded on a failing store", name)
+		}
+	}
+	fs.fail = false
+	if err = s.Put("c", ServerStore{SID: "sid-c", Key: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Put("a", revoked); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if head, err := VerifyLedger(cfg, nil); err != nil || head.Seq != 3 {
+		t.Fatalf("VerifyLedger() = %+v, %v", head, err)
+	}
+	f, err := os.Open(filepath.Join(cfg.ServerStoreFilePath, ServerLedgerFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var ops []string
+	if _, err = readLedger(f, func(e LedgerEntry) error {
+		ops = append(ops, e.Op+" "+e.Key)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(ops, ", "); got != "put a, put c, revoke a" {
+		t.Errorf("ledger entries %s", got)
+	}
+}
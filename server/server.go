@@ -0,0 +1,657 @@
+/*
+ * File: server.go
+ * Project: mrsign
+ * Created Date: Tuesday, October 19th 2021, 12:16:54 pm
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zitelog/mrsign/protocol"
+)
+
+const _sessionTimeout = 2 * time.Minute
+
+type session struct {
+	key       string
+	negotiate *protocol.NegotiateMessage
+	challenge *protocol.MessageChallenge
+	created   time.Time
+	// supersede is the reason of a new revision of the signature
+	supersede string
+	// revision is the revision to verify
+	revision int
+	// account is the account that negotiated the session
+	account string
+}
+
+type event struct {
+	name    string
+	request string
+	remote  string
+	key     string
+	user    string
+	host    string
+	account string
+}
+
+func newEvent(name string, request *http.Request) *event {
+	id, _ := request.Context().Value(requestIDKey{}).(string)
+	return &event{name: name, request: id, remote: request.RemoteAddr, account: account(request)}
+}
+
+type requestIDKey struct{}
+
+type accountKey struct{}
+
+// account returns the account the request is authenticated with, empty
+// without accounts
+func account(request *http.Request) string {
+	name, _ := request.Context().Value(accountKey{}).(string)
+	return name
+}
+
+// withRequestID gives every request a random ID, answered in the
+// X-Request-Id header and logged with its events
+func withRequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b [16]byte
+		_, _ = rand.Read(b[:])
+		id := hex.EncodeToString(b[:])
+		w.Header().Set(protocol.HeaderRequestID, id)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func (ev *event) negotiate(key string, nm *protocol.NegotiateMessage) {
+	ev.key = key
+	ev.user = nm.UserName
+	ev.host = nm.HostName
+}
+
+type Server struct {
+	server        *http.Server
+	logger        *slog.Logger
+	cfg           *Config
+	users         map[string]UserConfig
+	store         SignatureStore
+	ledger        *Ledger
+	signingKey    ed25519.PrivateKey
+	tsa           *TSAClient
+	sessionsMutex sync.Mutex
+	sessions      map[string]*session
+	keys          keyLocks
+}
+
+func NewServer(cfg *Config) (*Server, error) {
+	store, err := newSignatureStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var mux = http.NewServeMux()
+	var s = &Server{
+		logger:   slog.Default(),
+		cfg:      cfg,
+		store:    store,
+		ledger:   store.ledger,
+		sessions: make(map[string]*session),
+	}
+	s.server = &http.Server{
+		Addr:    cfg.Listen,
+		Handler: withRequestID(mux),
+
+		//WriteTimeout: time.Duration(writeTimeout) * time.Second,
+		//ReadTimeout:  time.Duration(readTimeout) * time.Second,
+	}
+
+	if cfg.Signing.Enable {
+		keyFile := cfg.Signing.Key
+		if len(keyFile) == 0 {
+			keyFile = DefaultSigningKeyFile
+		}
+		if s.signingKey, err = LoadSigningKey(keyFile); err != nil {
+			_ = store.Close()
+			return nil, err
+		}
+	}
+
+	if cfg.TSA.Enable {
+		if s.tsa, err = NewTSAClient(cfg.TSA.Url, cfg.TSA.Cert); err != nil {
+			_ = store.Close()
+			return nil, err
+		}
+	}
+
+	var authenticator = s.noAuthHandler
+	if s.cfg.Users.Enable {
+		authenticator = s.basicAuthHandler
+		s.users = make(map[string]UserConfig)
+		for _, user := range cfg.Users.Accounts {
+			s.users[user.User] = user
+		}
+	}
+
+	mux.HandleFunc(protocol.ApiChallenge, authenticator(s.challengeHandler))
+	mux.HandleFunc(protocol.ApiRetrieve, authenticator(s.retrieveHandler))
+	mux.HandleFunc(protocol.ApiPublicKey, s.noAuthHandler(s.publicKeyHandler))
+	mux.HandleFunc(protocol.ApiLedgerHead, s.noAuthHandler(s.ledgerHeadHandler))
+	mux.HandleFunc(protocol.ApiSignatures, authenticator(s.signaturesHandler))
+	mux.HandleFunc(protocol.ApiSignatures+"/", authenticator(s.signaturesHandler))
+
+	return s, nil
+}
+
+func (api *Server) SetLogger(logger *slog.Logger) {
+	api.logger = logger
+}
+
+func (api *Server) Start() error {
+	api.logger.Info("starting server", "listen", api.cfg.Listen, "store", api.cfg.ServerStoreFilePath, "storeType", api.cfg.StoreType, "tls", api.cfg.Secure.Enable)
+	var err error
+	if api.cfg.Secure.Enable {
+		err = api.server.ListenAndServeTLS(api.cfg.Secure.Cert, api.cfg.Secure.Key)
+	} else {
+		err = api.server.ListenAndServe()
+	}
+	_ = api.store.Close()
+	return err
+}
+
+func (api *Server) Handler() http.Handler {
+	return api.server.Handler
+}
+
+func (api *Server) serveHTTP(h http.HandlerFunc, w http.ResponseWriter, request *http.Request) {
+	var err error
+
+	defer func() {
+		var r = recover()
+		if r != nil {
+			switch t := r.(type) {
+			case string:
+				err = errors.New(t)
+			case error:
+				err = t
+			default:
+				err = errors.New("unknown error")
+			}
+			api.fail(w, newEvent("panic", request), slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		}
+	}()
+	h.ServeHTTP(w, request)
+}
+
+func (api *Server) verifyAccount(account string, password string) bool {
+	var ret = false
+	if user, ok := api.users[account]; ok {
+		var hash = protocol.GenerateHash(password)
+		if user.Hash == hash {
+			ret = true
+		}
+	}
+	return ret
+}
+
+func (api *Server) noAuthHandler(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		api.serveHTTP(h, w, r)
+	}
+}
+
+func (api *Server) basicAuthHandler(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+		var username, password, ok = r.BasicAuth()
+		if !ok {
+			api.fail(w, newEvent("auth", r), slog.LevelWarn, protocol.ErrorUnauthorized, "unsupported authorization", http.StatusUnauthorized)
+			return
+		}
+		if ok = api.verifyAccount(username, password); !ok {
+			api.fail(w, newEvent("auth", r), slog.LevelWarn, protocol.ErrorUnauthorized, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		api.serveHTTP(h, w, r.WithContext(context.WithValue(r.Context(), accountKey{}, username)))
+	}
+}
+
+func (api *Server) challengeHandler(w http.ResponseWriter, request *http.Request) {
+	ev := newEvent("challenge", request)
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	headers, err := protocol.ReadHeaders(body)
+	if err != nil {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorBadRequest, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch headers.MessageType {
+	case 1:
+		api.challengeNegotiate(w, ev, body, request.URL.Query())
+	case 3:
+		api.challengeAuthenticate(w, ev, body)
+	default:
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorBadRequest, "unexpected message", http.StatusBadRequest)
+	}
+}
+
+func (api *Server) challengeNegotiate(w http.ResponseWriter, ev *event, body []byte, query url.Values) {
+	reqNegotiate := protocol.NewMessageNegotiate()
+	if err := reqNegotiate.Unmarshal(body); err != nil {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorBadRequest, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reason := query.Get(protocol.ParamSupersede)
+	if query.Has(protocol.ParamSupersede) && len(reason) == 0 {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorBadRequest, "missing supersede reason", http.StatusBadRequest)
+		return
+	}
+	key := reqNegotiate.CreateKey()
+	ev.negotiate(key, reqNegotiate)
+	if reqNegotiate.Fields.Flags&protocol.NegotiateFlagsMAC == 0 {
+		// HMAC-MD5 is left to the verification of the legacy signatures
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorBadRequest, "unsupported mac: "+protocol.MacMD5, http.StatusBadRequest)
+		return
+	}
+	current, ok, err := api.store.Get(key)
+	if err != nil {
+		api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !api.checkSupersede(w, ev, current, ok, reason) {
+		return
+	}
+	resChallenge := protocol.NewMessageChallenge()
+	if err := resChallenge.Build(reqNegotiate); err != nil {
+		api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.writeChallenge(w, ev, &session{key: key, negotiate: reqNegotiate, challenge: resChallenge, supersede: reason})
+}
+
+// checkSupersede fails a new signature of a signed key, and a new revision
+// of a key without signature or signed by another account
+func (api *Server) checkSupersede(w http.ResponseWriter, ev *event, current ServerStore, exists bool, reason string) bool {
+	if exists && len(reason) == 0 {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorConflict, "already exists", http.StatusConflict)
+		return false
+	}
+	if exists && !api.mayChange(ev.account, current) {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorForbidden, "not allowed to supersede the signature of another account", http.StatusForbidden)
+		return false
+	}
+	if !exists && len(reason) > 0 {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorNotFound, "nothing to supersede", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+func (api *Server) challengeAuthenticate(w http.ResponseWriter, ev *event, body []byte) {
+	reqAuthenticate := protocol.NewMessageAuthenticate()
+	if err := reqAuthenticate.UnMarshal(body); err != nil {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorBadRequest, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sess, ok := api.takeSession(reqAuthenticate, ev.account)
+	if !ok {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorInvalidSession, "invalid session", http.StatusForbidden)
+		return
+	}
+	ev.negotiate(sess.key, sess.negotiate)
+	// the signature of the key must not change until the new one is stored
+	defer api.keys.lock(sess.key)()
+	current, ok, err := api.store.Get(sess.key)
+	if err != nil {
+		api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !api.checkSupersede(w, ev, current, ok, sess.supersede) {
+		return
+	}
+
+	var store ServerStore
+	store.Revision = 1
+	store.SID = protocol.Hex128(protocol.NextUUID())
+	store.Key = sess.key
+	store.User = sess.negotiate.UserName
+	store.HostName = sess.negotiate.HostName
+	store.Path = sess.negotiate.FolderName
+	store.Timestamp = hex.EncodeToString(sess.challenge.TargetInfo[protocol.AvIDMsvAvTimestamp])
+	store.ServerChallenge = hex.EncodeToString(sess.challenge.Fields.ServerChallenge[:])
+	store.Result = reqAuthenticate.Hash
+	store.Algorithm = sess.challenge.Fields.Flags.Mac()
+	store.Metadata = sess.negotiate.Fields.Flags.Has(protocol.NegotiateFlagNEGOTIATEMETADATA)
+	store.Account = sess.account
+	if ok {
+		store = current.Supersede(store, sess.supersede)
+	}
+	if api.tsa != nil {
+		token, err := api.tsa.Timestamp(store.Result)
+		if err != nil {
+			api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusBadGateway)
+			return
+		}
+		store.TimestampToken = token
+	}
+	if api.signingKey != nil {
+		receipt := store.Receipt()
+		receipt.Sign(api.signingKey)
+		store.KeyID = receipt.KeyID
+		store.Signature = receipt.Signature
+	}
+
+	if err := api.store.Put(store.Key, store); err != nil {
+		api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	args := []any{"sid", store.SID, "path", store.Path, "mac", store.Algorithm, "metadata", store.Metadata, "revision", store.Revision}
+	if ok {
+		args = append(args, "supersedes", store.Supersedes, "reason", store.Reason)
+	}
+	api.logEvent(ev, slog.LevelInfo, "signed", args...)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(store.Receipt())
+}
+
+func (api *Server) retrieveHandler(w http.ResponseWriter, request *http.Request) {
+	ev := newEvent("retrieve", request)
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	headers, err := protocol.ReadHeaders(body)
+	if err != nil {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorBadRequest, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch headers.MessageType {
+	case 1:
+		api.retrieveNegotiate(w, ev, body, request.URL.Query())
+	case 3:
+		api.retrieveAuthenticate(w, ev, body)
+	default:
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorBadRequest, "unexpected message", http.StatusBadRequest)
+	}
+}
+
+func (api *Server) retrieveNegotiate(w http.ResponseWriter, ev *event, body []byte, query url.Values) {
+	reqNegotiate := protocol.NewMessageNegotiate()
+	if err := reqNegotiate.Unmarshal(body); err != nil {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorBadRequest, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key := reqNegotiate.CreateKey()
+	ev.negotiate(key, reqNegotiate)
+	store, ok, err := api.store.Get(key)
+	if err != nil {
+		api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorNotFound, "not found", http.StatusNotFound)
+		return
+	}
+	if query.Has(protocol.ParamRevision) {
+		n, err := strconv.Atoi(query.Get(protocol.ParamRevision))
+		if err != nil {
+			api.fail(w, ev, slog.LevelWarn, protocol.ErrorBadRequest, "invalid revision", http.StatusBadRequest)
+			return
+		}
+		if store, ok = store.AtRevision(n); !ok {
+			api.fail(w, ev, slog.LevelWarn, protocol.ErrorNotFound, "revision not found", http.StatusNotFound)
+			return
+		}
+	}
+	if reqNegotiate.Fields.Flags.Has(protocol.NegotiateFlagNEGOTIATEMETADATA) != store.Metadata {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorMismatch, "different hash mode", http.StatusForbidden)
+		return
+	}
+	serverChallenge, err := hex.DecodeString(store.ServerChallenge)
+	if err != nil {
+		api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	timestamp, err := hex.DecodeString(store.Timestamp)
+	if err != nil {
+		api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resChallenge := protocol.NewMessageChallenge()
+	resChallenge.Fields.Flags = reqNegotiate.Fields.Flags
+	resChallenge.Fields.Flags.Set(protocol.NegotiateFlagNEGOTIATEUNICODE)
+	if err = resChallenge.Fields.Flags.SetMac(store.Mac()); err != nil {
+		api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !reqNegotiate.Fields.Flags.Has(resChallenge.Fields.Flags & protocol.NegotiateFlagsMAC) {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorBadRequest, "unsupported mac: "+store.Mac(), http.StatusBadRequest)
+		return
+	}
+	resChallenge.Fields.UUID = protocol.NextUUID()
+	copy(resChallenge.Fields.ServerChallenge[:], serverChallenge)
+	resChallenge.TargetInfo = map[protocol.AvID][]byte{protocol.AvIDMsvAvTimestamp: timestamp}
+	api.writeChallenge(w, ev, &session{key: key, negotiate: reqNegotiate, challenge: resChallenge, revision: store.RevisionNumber()})
+}
+
+func (api *Server) retrieveAuthenticate(w http.ResponseWriter, ev *event, body []byte) {
+	reqAuthenticate := protocol.NewMessageAuthenticate()
+	if err := reqAuthenticate.UnMarshal(body); err != nil {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorBadRequest, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sess, ok := api.takeSession(reqAuthenticate, ev.account)
+	if !ok {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorInvalidSession, "invalid session", http.StatusForbidden)
+		return
+	}
+	ev.negotiate(sess.key, sess.negotiate)
+	latest, ok, err := api.store.Get(sess.key)
+	if err != nil {
+		api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var store ServerStore
+	if ok {
+		store, ok = latest.AtRevision(sess.revision)
+	}
+	if !ok {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorNotFound, "not found", http.StatusNotFound)
+		return
+	}
+	if bytes.Compare(reqAuthenticate.Hash, store.Result) != 0 {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorMismatch, "different signature", http.StatusForbidden)
+		return
+	}
+	if r := store.Revocation; r != nil {
+		// the folder matches a signature that must not be relied upon
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorRevoked, "revoked on "+r.Time.Format(time.RFC3339)+" by "+r.By+": "+r.Reason, http.StatusGone)
+		return
+	}
+	args := []any{"sid", store.SID, "path", store.Path, "mac", store.Mac(), "revision", store.RevisionNumber(), "latest", latest.RevisionNumber()}
+	if len(store.TimestampToken) > 0 {
+		signedAt, err := api.verifyTimestamp(store)
+		if err != nil && !errors.Is(err, protocol.ErrUntrustedTimestamp) {
+			api.fail(w, ev, slog.LevelWarn, protocol.ErrorMismatch, "invalid timestamp token: "+err.Error(), http.StatusForbidden)
+			return
+		}
+		args = append(args, "timestamped", signedAt)
+		if err != nil {
+			args = append(args, "timestamp", "untrusted")
+		}
+	}
+	api.logEvent(ev, slog.LevelInfo, "verified", args...)
+
+	// the receipt of the latest revision tells the client whether the
+	// verified one is superseded
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(latest.Receipt())
+}
+
+// verifyTimestamp checks the timestamp token of a signature against the
+// configured TSA certificates, it returns protocol.ErrUntrustedTimestamp
+// without
+func (api *Server) verifyTimestamp(store ServerStore) (time.Time, error) {
+	if api.tsa != nil {
+		return api.tsa.Verify(store.TimestampToken, store.Result)
+	}
+	digest := sha256.Sum256(store.Result)
+	return protocol.VerifyTimestampToken(store.TimestampToken, digest[:], nil)
+}
+
+func (api *Server) publicKeyHandler(w http.ResponseWriter, request *http.Request) {
+	ev := newEvent("publickey", request)
+	if api.signingKey == nil {
+		api.fail(w, ev, slog.LevelDebug, protocol.ErrorNotFound, "signing disabled", http.StatusNotFound)
+		return
+	}
+	data, err := protocol.MarshalPublicKey(api.signingKey.Public().(ed25519.PublicKey))
+	if err != nil {
+		api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	_, _ = w.Write(data)
+}
+
+// ledgerHeadHandler answers the current head of the ledger signed with the
+// receipt key, for the auditors to record it away from the server
+func (api *Server) ledgerHeadHandler(w http.ResponseWriter, request *http.Request) {
+	ev := newEvent("ledgerhead", request)
+	if api.signingKey == nil {
+		api.fail(w, ev, slog.LevelDebug, protocol.ErrorNotFound, "signing disabled", http.StatusNotFound)
+		return
+	}
+	head := api.ledger.Head()
+	signed := protocol.LedgerHead{Seq: head.Seq, Hash: head.Hash, Time: time.Now().UTC().Format(time.RFC3339)}
+	signed.Sign(api.signingKey)
+	api.logEvent(ev, slog.LevelInfo, "signed", "seq", signed.Seq, "hash", signed.Hash)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(signed)
+}
+
+func (api *Server) writeChallenge(w http.ResponseWriter, ev *event, sess *session) {
+	resChallengeBody, err := sess.challenge.Marshal()
+	if err != nil {
+		api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sess.created = time.Now()
+	sess.account = ev.account
+	api.addSession(sess)
+	api.logEvent(ev, slog.LevelDebug, "challenged")
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(resChallengeBody)
+}
+
+// fail logs the error and answers it as an ErrorResponse, the outcome being
+// its machine code
+func (api *Server) fail(w http.ResponseWriter, ev *event, level slog.Level, outcome string, err string, code int) {
+	api.logEvent(ev, level, outcome, "error", err, "status", code)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(protocol.ErrorResponse{Code: outcome, Message: err, RequestID: ev.request})
+}
+
+func (api *Server) logEvent(ev *event, level slog.Level, outcome string, args ...any) {
+	attrs := []any{
+		"request", ev.request,
+		"key", ev.key,
+		"user", ev.user,
+		"host", ev.host,
+		"remote", ev.remote,
+		"outcome", outcome,
+	}
+	api.logger.Log(context.Background(), level, ev.name, append(attrs, args...)...)
+}
+
+func (api *Server) addSession(sess *session) {
+	now := time.Now()
+	api.sessionsMutex.Lock()
+	for id, s := range api.sessions {
+		if now.Sub(s.created) > _sessionTimeout {
+			delete(api.sessions, id)
+		}
+	}
+	api.sessions[hex.EncodeToString(sess.challenge.Fields.UUID[:])] = sess
+	api.sessionsMutex.Unlock()
+}
+
+// takeSession returns the session of the authenticate message and ends it,
+// when the message matches its challenge and comes from the account that
+// negotiated it. A message that does not match leaves the session alone, for
+// a guessed ID must not cancel the session of someone else.
+func (api *Server) takeSession(am *protocol.MessageAuthenticate, account string) (*session, bool) {
+	id := hex.EncodeToString(am.UUId)
+	api.sessionsMutex.Lock()
+	defer api.sessionsMutex.Unlock()
+	sess, ok := api.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	if time.Since(sess.created) > _sessionTimeout {
+		delete(api.sessions, id)
+		return nil, false
+	}
+	if sess.account != account {
+		return nil, false
+	}
+	if !bytes.Equal(am.Timestamp, sess.challenge.TargetInfo[protocol.AvIDMsvAvTimestamp]) {
+		return nil, false
+	}
+	if am.NegotiateFlags.Mac() != sess.challenge.Fields.Flags.Mac() {
+		return nil, false
+	}
+	delete(api.sessions, id)
+	return sess, true
+}
@@ -0,0 +1,212 @@
+/*
+ * File: server_test.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zitelog/mrsign/protocol"
+)
+
+func TestTakeSession(t *testing.T) {
+	api, err := NewServer(&Config{ServerStoreFilePath: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nm := protocol.NewMessageNegotiate()
+	nm.Fields.Flags.Set(protocol.NegotiateFlagNEGOTIATEMACSHA256)
+	challenge := protocol.NewMessageChallenge()
+	if err = challenge.Build(nm); err != nil {
+		t.Fatal(err)
+	}
+	ev := &event{name: "challenge", account: "alice"}
+	api.writeChallenge(httptest.NewRecorder(), ev, &session{key: "key", negotiate: nm, challenge: challenge})
+
+	am := protocol.NewMessageAuthenticate()
+	am.UUId = challenge.Fields.UUID[:]
+	am.NegotiateFlags = challenge.Fields.Flags
+	am.Timestamp = []byte("guessed")
+	if _, ok := api.takeSession(am, "alice"); ok {
+		t.Fatal("session taken with a wrong timestamp")
+	}
+	am.Timestamp = challenge.TargetInfo[protocol.AvIDMsvAvTimestamp]
+	if _, ok := api.takeSession(am, "mallory"); ok {
+		t.Fatal("session taken by another account")
+	}
+	if _, ok := api.takeSession(am, "alice"); !ok {
+		t.Fatal("session cancelled by the invalid messages")
+	}
+	if _, ok := api.takeSession(am, "alice"); ok {
+		t.Fatal("session taken twice")
+	}
+}
+
+func TestChallengeLegacyMac(t *testing.T) {
+	api, err := NewServer(&Config{ServerStoreFilePath: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nm := protocol.NewMessageNegotiate()
+	nm.UserName = "BOB"
+	nm.FolderName = "/evidence"
+	body, err := nm.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	api.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, protocol.ApiChallenge, bytes.NewReader(body)))
+	var res protocol.ErrorResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &res)
+	if w.Code != http.StatusBadRequest || res.Code != protocol.ErrorBadRequest {
+		t.Errorf("status = %d, code = %q, want a bad request", w.Code, res.Code)
+	}
+}
+
+// negotiate starts the signature of the folder of nm, a new revision with
+// supersede, and returns its authenticate message
+func negotiate(t *testing.T, h http.Handler, nm *protocol.NegotiateMessage, supersede string) []byte {
+	t.Helper()
+	body, err := nm.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := protocol.ApiChallenge
+	if len(supersede) > 0 {
+		target += "?" + protocol.ParamSupersede + "=" + supersede
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("negotiate: %d %s", w.Code, w.Body.String())
+	}
+	challenge := protocol.NewMessageChallenge()
+	if err = challenge.Unmarshal(w.Body.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	am := protocol.NewMessageAuthenticate()
+	if err = am.Build(challenge, nm, []byte("h1:folder")); err != nil {
+		t.Fatal(err)
+	}
+	if body, err = am.Marshal(); err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// serveAll serves the requests at once and returns the number answered with
+// 200 OK
+func serveAll(h http.Handler, requests []*http.Request) int {
+	var wg sync.WaitGroup
+	var served atomic.Int32
+	start := make(chan struct{})
+	for _, r := range requests {
+		wg.Add(1)
+		go func(r *http.Request) {
+			defer wg.Done()
+			<-start
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code == http.StatusOK {
+				served.Add(1)
+			}
+		}(r)
+	}
+	close(start)
+	wg.Wait()
+	return int(served.Load())
+}
+
+// slowStore widens the window between reading a signature and storing the
+// next one
+type slowStore struct {
+	SignatureStore
+}
+
+func (s slowStore) Get(key string) (ServerStore, bool, error) {
+	store, ok, err := s.SignatureStore.Get(key)
+	time.Sleep(10 * time.Millisecond)
+	return store, ok, err
+}
+
+func TestConcurrentSign(t *testing.T) {
+	api, err := NewServer(&Config{ServerStoreFilePath: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.store = slowStore{api.store}
+	h := api.Handler()
+	nm := protocol.NewMessageNegotiate()
+	nm.Flags.Set(protocol.NegotiateFlagsMAC)
+	nm.UserName = "BOB"
+	nm.HostName = "PC01"
+	nm.FolderName = "/evidence"
+	const n = 8
+
+	requests := make([]*http.Request, n)
+	for i := range requests {
+		requests[i] = httptest.NewRequest(http.MethodPost, protocol.ApiChallenge, bytes.NewReader(negotiate(t, h, nm, "")))
+	}
+	if signed := serveAll(h, requests); signed != 1 {
+		t.Fatalf("%d first signatures stored, want 1", signed)
+	}
+
+	for i := range requests {
+		requests[i] = httptest.NewRequest(http.MethodPost, protocol.ApiChallenge, bytes.NewReader(negotiate(t, h, nm, "rehash")))
+	}
+	if signed := serveAll(h, requests); signed != n {
+		t.Fatalf("%d revisions stored, want %d", signed, n)
+	}
+	store, ok, err := api.store.Get(nm.CreateKey())
+	if err != nil || !ok {
+		t.Fatalf("Get() = %v, %v", ok, err)
+	}
+	if store.Revision != n+1 || len(store.Previous) != n {
+		t.Fatalf("revision %d with %d previous, want %d with %d", store.Revision, len(store.Previous), n+1, n)
+	}
+	for i, p := range store.Previous {
+		if p.Revision != i+1 {
+			t.Errorf("previous %d is revision %d", i, p.Revision)
+		}
+	}
+}
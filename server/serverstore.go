@@ -0,0 +1,164 @@
+/*
+ * File: serverStore.go
+ * Project: mrsign
+ * Created Date: Tuesday, October 19th 2021, 12:16:54 pm
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/zitelog/mrsign/protocol"
+)
+
+const ServerStoreFile = "zserver.store"
+
+type ServerStore struct {
+	SID             string `json:"sid"`
+	Key             string `json:"key"`
+	User            string `json:"user"`
+	HostName        string `json:"hostName"`
+	Path            string `json:"path"`
+	Timestamp       string `json:"timestamp"`
+	ServerChallenge string `json:"serverChallenge"`
+	Result          []byte `json:"result"`
+	Algorithm       string `json:"algorithm,omitempty"`
+	Metadata        bool   `json:"metadata,omitempty"`
+	KeyID           string `json:"keyId,omitempty"`
+	Signature       string `json:"signature,omitempty"`
+	TimestampToken  []byte `json:"timestampToken,omitempty"`
+	Revision        int    `json:"revision,omitempty"`
+	Supersedes      string `json:"supersedes,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	// Revocation withdraws the signature, nil when valid
+	Revocation *protocol.Revocation `json:"revocation,omitempty"`
+	// Account is the account that signed, empty without accounts
+	Account string `json:"account,omitempty"`
+	// Previous are the superseded revisions of the key, oldest first
+	Previous []ServerStore `json:"previous,omitempty"`
+}
+
+func (s ServerStore) Receipt() protocol.Receipt {
+	digest := sha256.Sum256(s.Result)
+	return protocol.Receipt{
+		SID:             s.SID,
+		Key:             s.Key,
+		Timestamp:       s.Timestamp,
+		Digest:          hex.EncodeToString(digest[:]),
+		Algorithm:       s.Mac(),
+		User:            s.User,
+		HostName:        s.HostName,
+		Path:            s.Path,
+		ServerChallenge: s.ServerChallenge,
+		KeyID:           s.KeyID,
+		Signature:       s.Signature,
+		TimestampToken:  s.TimestampToken,
+		Revision:        s.Revision,
+		Supersedes:      s.Supersedes,
+		Reason:          s.Reason,
+	}
+}
+
+// RevisionNumber returns the revision of the signature, 1 for the
+// signatures stored before the revisions
+func (s ServerStore) RevisionNumber() int {
+	if s.Revision == 0 {
+		return 1
+	}
+	return s.Revision
+}
+
+// History returns every revision of the key, oldest first
+func (s ServerStore) History() []ServerStore {
+	latest := s
+	latest.Previous = nil
+	return append(append([]ServerStore{}, s.Previous...), latest)
+}
+
+// AtRevision returns the revision n of the key
+func (s ServerStore) AtRevision(n int) (ServerStore, bool) {
+	for _, r := range s.History() {
+		if r.RevisionNumber() == n {
+			return r, true
+		}
+	}
+	return ServerStore{}, false
+}
+
+// FindRevision returns the revision sid of the key
+func (s ServerStore) FindRevision(sid string) (ServerStore, bool) {
+	for _, r := range s.History() {
+		if r.SID == sid {
+			return r, true
+		}
+	}
+	return ServerStore{}, false
+}
+
+// Supersede returns next as the new revision of the key, keeping s in its
+// history
+func (s ServerStore) Supersede(next ServerStore, reason string) ServerStore {
+	next.Revision = s.RevisionNumber() + 1
+	next.Supersedes = s.SID
+	next.Reason = reason
+	next.Previous = s.History()
+	return next
+}
+
+// Mac returns the MAC of the signature, HMAC-MD5 for the signatures stored
+// before the MAC negotiation
+func (s ServerStore) Mac() string {
+	if len(s.Algorithm) == 0 {
+		return protocol.MacMD5
+	}
+	return s.Algorithm
+}
+
+// Revoke returns the signature of the key with the revision sid revoked
+func (s ServerStore) Revoke(sid string, revocation protocol.Revocation) (ServerStore, bool) {
+	if s.SID == sid {
+		s.Revocation = &revocation
+		return s, true
+	}
+	previous := append([]ServerStore{}, s.Previous...)
+	for i := range previous {
+		if previous[i].SID == sid {
+			previous[i].Revocation = &revocation
+			s.Previous = previous
+			return s, true
+		}
+	}
+	return s, false
+}
@@ -0,0 +1,268 @@
+/*
+ * File: signatures.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zitelog/mrsign/protocol"
+)
+
+// Info returns the signature as listed by the API, supersededBy being the
+// SID of the revision that replaced it, if any
+func (s ServerStore) Info(supersededBy string) protocol.SignatureInfo {
+	info := protocol.SignatureInfo{
+		SID:          s.SID,
+		Key:          s.Key,
+		User:         s.User,
+		HostName:     s.HostName,
+		Path:         s.Path,
+		Algorithm:    s.Mac(),
+		Metadata:     s.Metadata,
+		Revision:     s.RevisionNumber(),
+		Supersedes:   s.Supersedes,
+		Reason:       s.Reason,
+		SupersededBy: supersededBy,
+		KeyID:        s.KeyID,
+		Timestamped:  len(s.TimestampToken) > 0,
+		Revocation:   s.Revocation,
+	}
+	info.Signed, _ = protocol.ParseFiletime(s.Timestamp)
+	return info
+}
+
+// signatureInfos returns the signatures of the stores, with their superseded
+// revisions when history
+func signatureInfos(stores []ServerStore, history bool) []protocol.SignatureInfo {
+	var out []protocol.SignatureInfo
+	for _, s := range stores {
+		if !history {
+			out = append(out, s.Info(""))
+			continue
+		}
+		revisions := s.History()
+		for i, r := range revisions {
+			supersededBy := ""
+			if i+1 < len(revisions) {
+				supersededBy = revisions[i+1].SID
+			}
+			out = append(out, r.Info(supersededBy))
+		}
+	}
+	return out
+}
+
+// FilterSignatures returns the page of the signatures of the stores matching
+// q, the oldest first
+func FilterSignatures(stores []ServerStore, q protocol.SignatureQuery) protocol.SignatureList {
+	var match []protocol.SignatureInfo
+	for _, info := range signatureInfos(stores, q.History) {
+		if len(q.User) > 0 && !strings.EqualFold(info.User, q.User) {
+			continue
+		}
+		if len(q.Host) > 0 && !strings.EqualFold(info.HostName, q.Host) {
+			continue
+		}
+		if !strings.HasPrefix(info.Path, q.Path) {
+			continue
+		}
+		if !q.From.IsZero() && info.Signed.Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && !info.Signed.Before(q.To) {
+			continue
+		}
+		match = append(match, info)
+	}
+	sort.SliceStable(match, func(i, j int) bool {
+		if !match[i].Signed.Equal(match[j].Signed) {
+			return match[i].Signed.Before(match[j].Signed)
+		}
+		return match[i].SID < match[j].SID
+	})
+	list := protocol.SignatureList{
+		Signatures: []protocol.SignatureInfo{},
+		Total:      len(match),
+		Offset:     q.Offset,
+		Limit:      q.Limit,
+	}
+	if q.Offset < len(match) {
+		end := len(match)
+		if q.Limit > 0 && q.Offset+q.Limit < end {
+			end = q.Offset + q.Limit
+		}
+		list.Signatures = match[q.Offset:end]
+	}
+	return list
+}
+
+// FindSignature returns the signature, or the superseded revision, of sid
+func FindSignature(stores []ServerStore, sid string) (protocol.SignatureInfo, bool) {
+	for _, info := range signatureInfos(stores, true) {
+		if info.SID == sid {
+			return info, true
+		}
+	}
+	return protocol.SignatureInfo{}, false
+}
+
+func (api *Server) signaturesHandler(w http.ResponseWriter, request *http.Request) {
+	ev := newEvent("signatures", request)
+	sid, one := strings.CutPrefix(request.URL.Path, protocol.ApiSignatures+"/")
+	if revoke, ok := strings.CutSuffix(sid, protocol.ApiRevoke); one && ok {
+		ev.name = "revoke"
+		api.revoke(w, ev, request, revoke)
+		return
+	}
+	if request.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorMethodNotAllowed, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	stores, err := api.store.List()
+	if err != nil {
+		api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var out any
+	if one {
+		info, ok := FindSignature(stores, sid)
+		if !ok {
+			api.fail(w, ev, slog.LevelDebug, protocol.ErrorNotFound, "not found", http.StatusNotFound)
+			return
+		}
+		api.logEvent(ev, slog.LevelDebug, "found", "sid", sid)
+		out = info
+	} else {
+		q, err := protocol.ParseSignatureQuery(request.URL.Query())
+		if err != nil {
+			api.fail(w, ev, slog.LevelWarn, protocol.ErrorBadRequest, err.Error(), http.StatusBadRequest)
+			return
+		}
+		list := FilterSignatures(stores, q)
+		api.logEvent(ev, slog.LevelDebug, "listed", "total", list.Total)
+		out = list
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// mayChange tells whether the account may supersede or revoke the revision:
+// the account that signed it or an admin, anyone without accounts
+func (api *Server) mayChange(account string, revision ServerStore) bool {
+	if !api.cfg.Users.Enable {
+		return true
+	}
+	return api.users[account].Admin || len(revision.Account) > 0 && revision.Account == account
+}
+
+// revoke withdraws the signature, or the superseded revision, of sid on
+// behalf of the account of the request, its remote address without accounts
+func (api *Server) revoke(w http.ResponseWriter, ev *event, request *http.Request, sid string) {
+	if request.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorMethodNotAllowed, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req protocol.RevokeRequest
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorBadRequest, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !protocol.ValidRevokeReason(req.Reason) {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorBadRequest, "invalid revocation reason: "+req.Reason, http.StatusBadRequest)
+		return
+	}
+	stores, err := api.store.List()
+	if err != nil {
+		api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	info, ok := FindSignature(stores, sid)
+	if !ok {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorNotFound, "not found", http.StatusNotFound)
+		return
+	}
+	ev.key, ev.user, ev.host = info.Key, info.User, info.HostName
+	// the signature of the key must not change until the revocation is stored
+	defer api.keys.lock(info.Key)()
+	store, ok, err := api.store.Get(info.Key)
+	if err != nil {
+		api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var revision ServerStore
+	if ok {
+		revision, ok = store.FindRevision(sid)
+	}
+	if !ok {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorNotFound, "not found", http.StatusNotFound)
+		return
+	}
+	if !api.mayChange(ev.account, revision) {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorForbidden, "not allowed to revoke the signature of another account", http.StatusForbidden)
+		return
+	}
+	if revision.Revocation != nil {
+		api.fail(w, ev, slog.LevelWarn, protocol.ErrorConflict, "already revoked", http.StatusConflict)
+		return
+	}
+	revocation := protocol.Revocation{
+		Reason:  req.Reason,
+		Comment: req.Comment,
+		By:      account(request),
+		Time:    time.Now().UTC(),
+	}
+	if len(revocation.By) == 0 {
+		revocation.By = ev.remote
+	}
+	store, _ = store.Revoke(sid, revocation)
+	if err = api.store.Put(info.Key, store); err != nil {
+		api.fail(w, ev, slog.LevelError, protocol.ErrorInternal, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.logEvent(ev, slog.LevelInfo, "revoked", "sid", sid, "reason", revocation.Reason, "by", revocation.By, "comment", revocation.Comment)
+	info, _ = FindSignature([]ServerStore{store}, sid)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(info)
+}
@@ -0,0 +1,181 @@
+/*
+ * File: signatures_test.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zitelog/mrsign/protocol"
+)
+
+// filetime encodes t as a server timestamp
+func filetime(t time.Time) string {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(t.UnixNano()/100)+116444736000000000)
+	return hex.EncodeToString(b)
+}
+
+func TestFilterSignatures(t *testing.T) {
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	store := func(sid string, user string, path string, days int) ServerStore {
+		return ServerStore{SID: sid, Key: "key-" + sid, User: user, HostName: "PC01", Path: path, Timestamp: filetime(day.AddDate(0, 0, days)), Result: []byte(sid), ServerChallenge: "00"}
+	}
+	old := store("s1", "BOB", "/cases/1", 0)
+	stores := []ServerStore{
+		old.Supersede(store("s2", "BOB", "/cases/1", 3), "second extraction"),
+		store("s3", "ALICE", "/cases/2", 1),
+		store("s4", "BOB", "/other", 2),
+	}
+
+	sids := func(l protocol.SignatureList) []string {
+		var out []string
+		for _, info := range l.Signatures {
+			out = append(out, info.SID)
+		}
+		return out
+	}
+	tests := []struct {
+		name  string
+		q     protocol.SignatureQuery
+		sids  []string
+		total int
+	}{
+		{"all", protocol.SignatureQuery{}, []string{"s3", "s4", "s2"}, 3},
+		{"history", protocol.SignatureQuery{History: true}, []string{"s1", "s3", "s4", "s2"}, 4},
+		{"user", protocol.SignatureQuery{User: "bob"}, []string{"s4", "s2"}, 2},
+		{"host", protocol.SignatureQuery{Host: "pc02"}, nil, 0},
+		{"path prefix", protocol.SignatureQuery{Path: "/cases/"}, []string{"s3", "s2"}, 2},
+		{"time range", protocol.SignatureQuery{From: day.AddDate(0, 0, 1), To: day.AddDate(0, 0, 3)}, []string{"s3", "s4"}, 2},
+		{"page", protocol.SignatureQuery{Offset: 1, Limit: 1}, []string{"s4"}, 3},
+		{"past the end", protocol.SignatureQuery{Offset: 5, Limit: 1}, nil, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := FilterSignatures(stores, tt.q)
+			got := sids(l)
+			if l.Total != tt.total || len(got) != len(tt.sids) {
+				t.Fatalf("got %v of %d, want %v of %d", got, l.Total, tt.sids, tt.total)
+			}
+			for i := range got {
+				if got[i] != tt.sids[i] {
+					t.Fatalf("got %v, want %v", got, tt.sids)
+				}
+			}
+		})
+	}
+
+	info, ok := FindSignature(stores, "s1")
+	if !ok || info.Revision != 1 || info.SupersededBy != "s2" || !info.Signed.Equal(day) {
+		t.Errorf("FindSignature(s1) = %+v, %v", info, ok)
+	}
+	if _, ok = FindSignature(stores, "s5"); ok {
+		t.Error("FindSignature found a missing SID")
+	}
+}
+
+func TestConcurrentRevoke(t *testing.T) {
+	api, err := NewServer(&Config{ServerStoreFilePath: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.store = slowStore{api.store}
+	h := api.Handler()
+	nm := protocol.NewMessageNegotiate()
+	nm.Flags.Set(protocol.NegotiateFlagsMAC)
+	nm.UserName = "BOB"
+	nm.FolderName = "/evidence"
+	for _, supersede := range []string{"", "rehash", "rehash", "rehash"} {
+		body := negotiate(t, h, nm, supersede)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, protocol.ApiChallenge, bytes.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("sign: %d %s", w.Code, w.Body.String())
+		}
+	}
+	store, _, err := api.store.Get(nm.CreateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every revision revoked at once, twice
+	var requests []*http.Request
+	for _, r := range append(store.History(), store.History()...) {
+		target := protocol.ApiSignatures + "/" + r.SID + protocol.ApiRevoke
+		requests = append(requests, httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"reason":"`+protocol.RevokeUnspecified+`"}`)))
+	}
+	if revoked := serveAll(h, requests); revoked != len(requests)/2 {
+		t.Fatalf("%d revocations stored, want %d", revoked, len(requests)/2)
+	}
+	if store, _, err = api.store.Get(nm.CreateKey()); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range store.History() {
+		if r.Revocation == nil {
+			t.Errorf("revision %d not revoked", r.Revision)
+		}
+	}
+}
+
+func TestSignaturesMethod(t *testing.T) {
+	api, err := NewServer(&Config{ServerStoreFilePath: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		method, target, allow string
+	}{
+		{http.MethodPost, protocol.ApiSignatures, http.MethodGet},
+		{http.MethodDelete, protocol.ApiSignatures + "/e03f4403", http.MethodGet},
+		{http.MethodGet, protocol.ApiSignatures + "/e03f4403" + protocol.ApiRevoke, http.MethodPost},
+	} {
+		w := httptest.NewRecorder()
+		api.Handler().ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+		var res protocol.ErrorResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &res)
+		if w.Code != http.StatusMethodNotAllowed || res.Code != protocol.ErrorMethodNotAllowed || w.Header().Get("Allow") != tt.allow {
+			t.Errorf("%s %s: status = %d, code = %q, allow = %q", tt.method, tt.target, w.Code, res.Code, w.Header().Get("Allow"))
+		}
+	}
+}
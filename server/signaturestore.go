@@ -0,0 +1,106 @@
+/*
+ * File: signaturestore.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package server
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	StoreTypeJson    = "json"
+	StoreTypeJournal = "journal"
+)
+
+type SignatureStore interface {
+	Put(key string, store ServerStore) error
+	Get(key string) (ServerStore, bool, error)
+	List() ([]ServerStore, error)
+	Delete(key string) error
+	Close() error
+}
+
+// NewSignatureStore opens the store selected by the config, recording every
+// change in the server ledger
+func NewSignatureStore(cfg *Config) (SignatureStore, error) {
+	return newSignatureStore(cfg)
+}
+
+func newSignatureStore(cfg *Config) (*ledgerStore, error) {
+	store, err := openSignatureStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ls, err := newLedgerStore(store, cfg.ServerStoreFilePath+string(os.PathSeparator)+ServerLedgerFile, cfg.LedgerInit)
+	if err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+	return ls, nil
+}
+
+func openSignatureStore(cfg *Config) (SignatureStore, error) {
+	switch cfg.StoreType {
+	case "", StoreTypeJson:
+		return NewJsonStore(cfg.ServerStoreFilePath + string(os.PathSeparator) + ServerStoreFile)
+	case StoreTypeJournal:
+		return NewJournalStore(cfg.ServerStoreFilePath + string(os.PathSeparator) + ServerJournalFile)
+	default:
+		return nil, fmt.Errorf("unknown store type: %s", cfg.StoreType)
+	}
+}
+
+// readSignatureStore returns the signatures of the store selected by the
+// config without changing its files, which the server may be writing
+func readSignatureStore(cfg *Config) (SignatureStore, error) {
+	if cfg.StoreType == StoreTypeJournal {
+		return readJournalStore(cfg.ServerStoreFilePath + string(os.PathSeparator) + ServerJournalFile)
+	}
+	// the JSON store only reads its file when opened, and replaces it whole
+	return openSignatureStore(cfg)
+}
+
+// ListSignatures returns the signatures of the store selected by the config,
+// read without taking part in the ledger
+func ListSignatures(cfg *Config) ([]ServerStore, error) {
+	store, err := readSignatureStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	return store.List()
+}
@@ -0,0 +1,169 @@
+/*
+ * File: signaturestore_test.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package server
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignatureStore(t *testing.T) {
+	for _, storeType := range []string{StoreTypeJson, StoreTypeJournal} {
+		t.Run(storeType, func(t *testing.T) {
+			cfg := &Config{ServerStoreFilePath: t.TempDir(), StoreType: storeType}
+			s, err := NewSignatureStore(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range []string{"b", "a", "c"} {
+				if err = s.Put(key, ServerStore{Key: key, User: "USER-" + key, Result: []byte(key)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = s.Delete("c"); err != nil {
+				t.Fatal(err)
+			}
+			if err = s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			s, err = NewSignatureStore(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			got, ok, err := s.Get("a")
+			if err != nil || !ok {
+				t.Fatalf("Get(a) = %v, %v", ok, err)
+			}
+			if got.User != "USER-a" || string(got.Result) != "a" {
+				t.Errorf("Get(a) = %+v", got)
+			}
+			if _, ok, _ = s.Get("c"); ok {
+				t.Error("deleted key still present")
+			}
+			list, err := s.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != 2 || list[0].Key != "a" || list[1].Key != "b" {
+				t.Errorf("List() = %+v", list)
+			}
+		})
+	}
+}
+
+func TestJournalStoreTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, ServerJournalFile)
+	s, err := NewJournalStore(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Put("a", ServerStore{Key: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Close()
+
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"op":"put","key":"b","sto`)
+	_ = f.Close()
+
+	s, err = NewJournalStore(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Put("c", ServerStore{Key: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Close()
+
+	s, err = NewJournalStore(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	list, _ := s.List()
+	if len(list) != 2 || list[0].Key != "a" || list[1].Key != "c" {
+		t.Errorf("List() = %+v", list)
+	}
+}
+
+func TestReadJournalStore(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), ServerJournalFile)
+	s, err := readJournalStore(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.List(); len(list) != 0 {
+		t.Errorf("List() of a missing journal = %+v", list)
+	}
+	if _, err = os.Stat(fileName); !os.IsNotExist(err) {
+		t.Fatalf("journal created by the read: %v", err)
+	}
+
+	w, err := NewJournalStore(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err = w.Put("a", ServerStore{Key: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	// a record the server is writing
+	_, _ = w.file.WriteString(`{"op":"put","key":"b","sto`)
+	before, _ := os.ReadFile(fileName)
+
+	if s, err = readJournalStore(fileName); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.List(); len(list) != 1 || list[0].Key != "a" {
+		t.Errorf("List() = %+v", list)
+	}
+	if err = s.Put("c", ServerStore{Key: "c"}); err == nil {
+		t.Error("Put() in a read-only journal")
+	}
+	_ = s.Close()
+	if after, _ := os.ReadFile(fileName); !bytes.Equal(before, after) {
+		t.Errorf("journal changed by the read: %q", after)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+ * File: tsa.go
+ * Project: mrsign
+ * Created Date: Saturday, October 17th 2026, 10:12:31 am
+ * Authors: Marcello Russo, Fabio Zito
+ * -----
+ * Last Modified:
+ * Modified By:
+ * -----
+ * MIT License
+ *
+ * Copyright (c) 2021 MR&&Z
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of
+ * this software and associated documentation files (the "Software"), to deal in
+ * the Software without restriction, including without limitation the rights to
+ * use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+ * of the Software, and to permit persons to whom the Software is furnished to do
+ * so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ * -----
+ * HISTORY:
+ * Date      	By	Comments
+ * ----------	---	----------------------------------------------------------
+ */
+
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/digitorus/timestamp"
+	"github.com/zitelog/mrsign/protocol"
+)
+
+const _tsaTimeout = 30 * time.Second
+
+// TSAClient requests RFC 3161 timestamp tokens from a Time Stamping Authority
+type TSAClient struct {
+	url    string
+	roots  *x509.CertPool
+	client *http.Client
+}
+
+// NewTSAClient returns a client of the TSA at url, certFile (optional) holds
+// the trusted TSA certificates
+func NewTSAClient(url string, certFile string) (*TSAClient, error) {
+	if len(url) == 0 {
+		return nil, errors.New("missing TSA url")
+	}
+	t := &TSAClient{
+		url:    url,
+		client: &http.Client{Timeout: _tsaTimeout},
+	}
+	if len(certFile) > 0 {
+		roots, err := protocol.LoadCertPool(certFile)
+		if err != nil {
+			return nil, err
+		}
+		t.roots = roots
+	}
+	return t, nil
+}
+
+// Timestamp returns a token over the SHA-256 of data
+func (t *TSAClient) Timestamp(data []byte) ([]byte, error) {
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+	req, err := timestamp.CreateRequest(bytes.NewReader(data), &timestamp.RequestOptions{
+		Hash:         crypto.SHA256,
+		Certificates: true,
+		Nonce:        nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res, err := t.client.Post(t.url, "application/timestamp-query", bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New("tsa: invalid status code: " + res.Status)
+	}
+	ts, err := timestamp.ParseResponse(body)
+	if err != nil {
+		return nil, err
+	}
+	if ts.Nonce == nil || ts.Nonce.Cmp(nonce) != 0 {
+		return nil, errors.New("tsa: nonce mismatch")
+	}
+	// without TSA certificates the token of the configured TSA is kept, its
+	// trust is left to whoever verifies it
+	if _, err = t.Verify(ts.RawToken, data); err != nil && !errors.Is(err, protocol.ErrUntrustedTimestamp) {
+		return nil, err
+	}
+	return ts.RawToken, nil
+}
+
+// Verify checks that token covers data and is signed by a trusted TSA, it
+// returns protocol.ErrUntrustedTimestamp without TSA certificates
+func (t *TSAClient) Verify(token []byte, data []byte) (time.Time, error) {
+	digest := sha256.Sum256(data)
+	return protocol.VerifyTimestampToken(token, digest[:], t.roots)
+}